@@ -2,21 +2,35 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/gob"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/fsnotify/fsnotify"
+	"github.com/mattn/go-runewidth"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
 )
 
 var version = "dev"
@@ -24,10 +38,27 @@ var version = "dev"
 // Message represents a conversation message
 type Message struct {
 	Role string `json:"role"`
+	Kind string `json:"kind,omitempty"` // "" for dialogue text, else kindToolUse/kindToolResult/kindThinking
+	Tool string `json:"tool,omitempty"` // tool name of a kindToolUse message
 	Text string `json:"text"`
 	Ts   string `json:"ts"`
 }
 
+// Message kinds besides dialogue. Tool calls (assistant), their results (user)
+// and extended-thinking blocks (assistant) are kept as messages of their own
+// so they can be searched on request without counting as dialogue.
+const (
+	kindToolUse    = "tool_use"
+	kindToolResult = "tool_result"
+	kindThinking   = "thinking"
+)
+
+// dialogue reports whether msg is user or assistant text, not tool traffic or
+// thinking.
+func (msg Message) dialogue() bool {
+	return msg.Kind == ""
+}
+
 // Conversation represents a parsed conversation
 type Conversation struct {
 	SessionID      string    `json:"session_id"`
@@ -39,31 +70,152 @@ type Conversation struct {
 	Messages       []Message `json:"messages"`
 	FilePath       string    `json:"file_path"` // Full path to the .jsonl file
 	Size           int64     `json:"size"`      // .jsonl file size in bytes
+	Branches       []string  `json:"branches"`  // git branches the session ran on, in the order first seen
+	Models         []string  `json:"models"`    // models that answered, in the order first seen
+
+	repo string // main checkout of Cwd's git repository ("" outside one), set by buildItems
+}
+
+// messageCount is the number of dialogue messages (the MSGS column).
+func (c Conversation) messageCount() int {
+	n := 0
+	for _, msg := range c.Messages {
+		if msg.dialogue() {
+			n++
+		}
+	}
+	return n
+}
+
+// project is where the conversation belongs: its repository, so sessions in
+// different worktrees group together, or else its working directory.
+func (c Conversation) project() string {
+	return cmp.Or(c.repo, c.Cwd)
+}
+
+// worktree reports whether the conversation ran in a linked worktree rather
+// than the repository's main checkout.
+func (c Conversation) worktree() bool {
+	return c.repo != "" && !underDir(c.Cwd, c.repo)
+}
+
+// latestBranch is the BRANCH column: the latest branch the session switched
+// to, and how many others it ran on.
+func (c Conversation) latestBranch() (string, int) {
+	if len(c.Branches) == 0 {
+		return "", 0
+	}
+	return c.Branches[len(c.Branches)-1], len(c.Branches) - 1
 }
 
 // RawMessage represents the JSON structure in conversation files
 type RawMessage struct {
-	Type    string `json:"type"`
-	Cwd     string `json:"cwd"`
-	Message struct {
+	Type      string `json:"type"`
+	Cwd       string `json:"cwd"`
+	GitBranch string `json:"gitBranch"`
+	Message   struct {
 		Content json.RawMessage `json:"content"`
+		Model   string          `json:"model"`
 	} `json:"message"`
 	Timestamp   string `json:"timestamp"`
 	CustomTitle string `json:"customTitle"`
 	AiTitle     string `json:"aiTitle"`
 }
 
-// TextContent for parsing content arrays
+// TextContent for parsing content arrays: text, tool_use and tool_result items
 type TextContent struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type     string          `json:"type"`
+	Text     string          `json:"text"`
+	Thinking string          `json:"thinking"` // thinking
+	Name     string          `json:"name"`     // tool_use
+	Input    json.RawMessage `json:"input"`    // tool_use
+	Content  json.RawMessage `json:"content"`  // tool_result: a string or content array
 }
 
 // listItem holds display and search data for a conversation
 type listItem struct {
 	conv        Conversation
-	searchText  string // All searchable content
-	searchLower string // searchText lowercased once, for case-insensitive filtering
+	searchText  string                         // All searchable content
+	searchLower string                         // searchText folded once (see fold), for case- and accent-insensitive filtering
+	toolText    string                         // tool calls and results, built only while searchOpts.tools (see index)
+	toolLower   string                         // toolText folded
+	thinkText   string                         // thinking blocks, built only while searchOpts.thinking
+	thinkLower  string                         // thinkText folded
+	msgLower    []string                       // each message's folded text, a slice of the above ("" if not indexed)
+	score       float64                        // rank under the current query (fuzzy or relevance), higher is better
+	indexed     struct{ tools, thinking bool } // which of the above are built
+}
+
+// index builds the tool and thinking search text that opts searches, and drops
+// what it doesn't: tool traffic can outweigh the dialogue many times over, so
+// it is only held in memory while it is searched.
+func (item *listItem) index(opts searchOpts) {
+	if opts.tools != item.indexed.tools {
+		item.toolText, item.toolLower = item.indexMessages(opts.tools, func(msg Message) bool { return msg.Kind == kindToolUse || msg.Kind == kindToolResult })
+		item.indexed.tools = opts.tools
+	}
+	if opts.thinking != item.indexed.thinking {
+		item.thinkText, item.thinkLower = item.indexMessages(opts.thinking, func(msg Message) bool { return msg.Kind == kindThinking })
+		item.indexed.thinking = opts.thinking
+	}
+}
+
+// indexMessages joins and folds the text of the messages keep selects,
+// recording each one's folded text in msgLower - or, without on, drops them.
+func (item *listItem) indexMessages(on bool, keep func(Message) bool) (text, lower string) {
+	// Copies of the item (the filtered list) share msgLower; don't write
+	// through to them.
+	msgLower := make([]string, len(item.conv.Messages))
+	copy(msgLower, item.msgLower)
+	item.msgLower = msgLower
+	var parts []string
+	var at []int
+	for i, msg := range item.conv.Messages {
+		if keep(msg) {
+			msgLower[i] = ""
+			if on {
+				parts = append(parts, msg.Text)
+				at = append(at, i)
+			}
+		}
+	}
+	if !on {
+		return "", ""
+	}
+	lower, folded := foldJoin(parts)
+	for k, i := range at {
+		msgLower[i] = folded[k]
+	}
+	return strings.Join(parts, " "), lower
+}
+
+// messageLower is message i's folded text, from the index where it holds it.
+func (item listItem) messageLower(i int) string {
+	if i < len(item.msgLower) && item.msgLower[i] != "" {
+		return item.msgLower[i]
+	}
+	return fold(item.conv.Messages[i].Text)
+}
+
+// sortOrder is how the filtered list is ordered.
+type sortOrder int
+
+// Ctrl+S cycles through them in this order. Each has a natural direction -
+// newest, largest or best first, titles A to Z - which Alt+S reverses.
+const (
+	sortRecent    sortOrder = iota // last activity, newest first (load order)
+	sortRelevance                  // BM25 score of the query's free text
+	sortStarted                    // first activity
+	sortSize                       // file size
+	sortMsgs                       // message count
+	sortHits                       // messages matching the query
+	sortTitle                      // session name or first message
+	numSortOrders
+)
+
+// needsQuery reports whether an order only means something while searching.
+func (s sortOrder) needsQuery() bool {
+	return s == sortRelevance || s == sortHits
 }
 
 // selectedStyle highlights the cursor row. The rest of the UI is rendered with
@@ -75,28 +227,64 @@ var selectedStyle = lipgloss.NewStyle().
 
 // model is the bubbletea application state
 type model struct {
-	items          []listItem
-	filtered       []listItem
-	textInput      textinput.Model
-	cursor         int
-	previewScroll  int
-	width          int
-	height         int
-	listHeight     int // Calculated visible list height
-	selected       *Conversation
-	quitting       bool
-	claudeFlags    []string
-	confirmDelete  bool   // Are we in delete confirmation mode?
-	deleteIndex    int    // Index of item to delete
-	confirmPrune   bool   // Are we in prune confirmation mode?
-	pruneIndex     int    // Index of item to prune
-	pruneSaved     int64  // Bytes the pending prune would reclaim (measured on Ctrl+R)
-	errorMsg        string // Show deletion/prune errors
-	preview         *previewCache // memoised preview lines for the selected conversation
-	hits            *hitCounter   // memoised per-query hit counts, keyed by SessionID
-	lastFilterQuery string        // lowercased query the current m.filtered was built from
+	items         []listItem
+	filtered      []listItem
+	matched       []listItem // the query's matches before facet choices narrow them to filtered
+	textInput     textinput.Model
+	cursor        int
+	previewScroll int
+	width         int
+	height        int
+	layout        layoutMode // list and preview arrangement (Alt+L)
+	listPct       int        // list pane's share of the screen in percent (0: the layout's default)
+	selected      *Conversation
+	quitting      bool
+	claudeFlags   []string
+	confirmDelete bool            // Are we in delete confirmation mode?
+	deleteIndex   int             // Index of item to delete
+	confirmPrune  bool            // Are we in prune confirmation mode?
+	pruneIndex    int             // Index of item to prune
+	pruneSaved    int64           // Bytes the pending prune would reclaim (measured on Ctrl+R)
+	confirmExport bool            // Are we in export confirmation mode?
+	exports       []Conversation  // what the pending export writes (taken on Ctrl+X)
+	exportDir     string          // where Ctrl+X writes conversations to
+	marked        map[string]bool // SessionIDs marked with Tab for a batch delete/prune/export
+	batch         bool            // the pending delete/prune/export is of the marked conversations
+	errorMsg      string          // Show deletion/prune errors
+	preview       *previewCache   // memoised preview lines for the selected conversation
+	hits          *hitCounter     // memoised per-query hit counts, keyed by SessionID
+	opts          searchOpts      // search modes toggled by key or flag
+	sortBy        sortOrder       // list order (Ctrl+S)
+	sortReverse   bool            // sortBy against its natural direction (Alt+S)
+	display       previewOpts     // preview rendering toggled by key
+	query         searchQuery     // parsed search box value
+	lastQuery     *searchQuery    // query the current m.filtered was built from (nil: none)
+	loading       bool            // background loader still parsing files
+	loadDone      int             // files processed by the background loader
+	loadTotal     int             // files the background loader will process
+	viewer        *transcript     // full-screen transcript viewer (nil: showing the list)
+	facets        facets          // facet sidebar (Alt+G)
+	here          bool            // list only conversations in scope (--here, Alt+H)
+	scope         hereScope       // the current directory's project
+	showBranch    bool            // BRANCH column (--branch-column, Alt+V)
+}
+
+// previewOpts are display toggles for the preview that don't affect matching.
+type previewOpts struct {
+	expandThinking bool // show thinking blocks in full rather than collapsed
+	context        int  // runes of context around each match in an excerpt (0: excerptContext)
+	width          int  // wrap lines to this many terminal cells (0: don't wrap)
+	raw            bool // show message text as written, without markdown styling
 }
 
+// Long messages are cut to previewMaxRunes: from the start, or - if they
+// match - to excerpts around the matches.
+const (
+	previewMaxRunes   = 500
+	excerptContext    = 150 // default previewOpts.context
+	excerptMaxWindows = 8   // excerpts per message; further matches are counted
+)
+
 // previewCache memoises buildPreviewLines for the selected conversation so the
 // preview isn't rebuilt (scanning every message) on every frame. It lives behind
 // a pointer so it survives the value-receiver copies of model that View makes.
@@ -109,16 +297,18 @@ type previewCache struct {
 // the current query, so formatListItem doesn't rescan every visible row's
 // messages on every frame. Pointer-held so it survives model value copies.
 type hitCounter struct {
-	query string
+	query string // searchQuery.key()
 	byID  map[string]int
 }
 
-// countHits is the number of a conversation's messages containing query.
-func countHits(conv Conversation, query string) int {
-	queryLower := strings.ToLower(query)
+// countHits is the number of a conversation's messages matching q.
+func countHits(conv Conversation, q searchQuery) int {
+	if !q.hasText() {
+		return 0
+	}
 	n := 0
 	for _, msg := range conv.Messages {
-		if strings.Contains(strings.ToLower(msg.Text), queryLower) {
+		if q.searches(msg) && q.matchMessage(msg) {
 			n++
 		}
 	}
@@ -127,22 +317,22 @@ func countHits(conv Conversation, query string) int {
 
 // hitCount returns the memoised hit count for item under the current query.
 func (m model) hitCount(item listItem) int {
-	query := m.textInput.Value()
-	if query == "" {
+	q := m.currentQuery()
+	if !q.hasText() {
 		return 0
 	}
 	if m.hits == nil { // model built without initialModel (e.g. tests)
-		return countHits(item.conv, query)
+		return countHits(item.conv, q)
 	}
-	if m.hits.query != query {
-		m.hits.query = query
+	if key := q.key(); m.hits.query != key {
+		m.hits.query = key
 		m.hits.byID = make(map[string]int)
 	}
 	id := item.conv.SessionID
 	if h, ok := m.hits.byID[id]; ok {
 		return h
 	}
-	h := countHits(item.conv, query)
+	h := countHits(item.conv, q)
 	m.hits.byID[id] = h
 	return h
 }
@@ -161,54 +351,87 @@ func initialModel(items []listItem, filterQuery string, claudeFlags []string) mo
 		claudeFlags: claudeFlags,
 		preview:     &previewCache{},
 		hits:        &hitCounter{byID: make(map[string]int)},
+		exportDir:   "ccs-export",
 	}
 	m.updateFilter()
 	return m
 }
 
 // previewLines returns the preview lines for the selected conversation,
-// rebuilding only when the selection or query changes. Keyed by SessionID (not
+// rebuilding only when the selection, query or display options change. Keyed by SessionID (not
 // cursor index) so it stays correct when the filtered list shifts.
 func (m model) previewLines() []string {
 	if len(m.filtered) == 0 {
 		return nil
 	}
 	conv := m.filtered[m.cursor].conv
-	query := m.textInput.Value()
+	q := m.currentQuery()
 	if m.preview == nil { // model built without initialModel (e.g. tests)
-		return buildPreviewLines(conv, query)
+		return buildPreviewLines(conv, q, m.display)
 	}
-	key := conv.SessionID + "\x00" + query
+	key := fmt.Sprintf("%s\x00%s\x00%+v", conv.SessionID, q.key(), m.display)
 	if m.preview.key != key {
 		m.preview.key = key
-		m.preview.lines = buildPreviewLines(conv, query)
+		m.preview.lines = buildPreviewLines(conv, q, m.display)
 	}
 	return m.preview.lines
 }
 
+// currentQuery is the parsed search box value under the active search modes.
+// It is normally m.query, but is reparsed if the input changed without a
+// refilter (value-receiver callers can't store it).
+func (m model) currentQuery() searchQuery {
+	value := m.textInput.Value()
+	if m.query.raw == value && m.query.opts == m.opts {
+		return m.query
+	}
+	return parseQueryOpts(value, m.opts)
+}
+
 func (m *model) updateFilter() {
-	queryLower := strings.ToLower(m.textInput.Value())
-	if queryLower == "" {
+	q := m.currentQuery()
+	m.query = q
+	if q.err != nil {
+		// Keep showing the last valid result set; View reports the error.
+		return
+	}
+	for i := range m.items {
+		m.items[i].index(q.opts)
+	}
+	if q.empty() {
 		// Make a copy to avoid sharing backing array with m.items
-		m.filtered = make([]listItem, len(m.items))
-		copy(m.filtered, m.items)
+		m.filtered = make([]listItem, 0, len(m.items))
+		for _, item := range m.items {
+			if m.inScope(item) {
+				m.filtered = append(m.filtered, item)
+			}
+		}
 	} else {
-		// Incremental narrowing: if the new query contains the previous one, every
-		// item matching the new query already matched the old one, so filter the
-		// previous (smaller) result set instead of rescanning every conversation.
+		// Incremental narrowing: if every item matching the new query also
+		// matched the previous one, filter the previous (smaller) result set
+		// instead of rescanning every conversation.
 		source := m.items
-		if m.lastFilterQuery != "" && strings.Contains(queryLower, m.lastFilterQuery) {
-			source = m.filtered
+		if m.lastQuery != nil && q.narrows(*m.lastQuery) {
+			source = m.matched
 		}
 		next := make([]listItem, 0, len(source))
 		for _, item := range source {
-			if strings.Contains(item.searchLower, queryLower) {
+			if !m.inScope(item) {
+				continue
+			}
+			if score, ok := q.matchScore(item); ok {
+				item.score = score
 				next = append(next, item)
 			}
 		}
 		m.filtered = next
 	}
-	m.lastFilterQuery = queryLower
+	m.matched = m.filtered
+	if m.facets.open {
+		m.filtered = m.facets.filter(m.matched)
+	}
+	m.rank(q)
+	m.lastQuery = &q
 	// Keep cursor in bounds
 	if m.cursor >= len(m.filtered) {
 		m.cursor = max(0, len(m.filtered)-1)
@@ -216,6 +439,128 @@ func (m *model) updateFilter() {
 	m.previewScroll = 0
 }
 
+// ranked reports whether the list is ordered by score: by relevance while
+// searching, and fuzzy matches unless another order was chosen.
+func (m model) ranked(q searchQuery) bool {
+	switch m.sortBy {
+	case sortRecent:
+		return q.ranked()
+	case sortRelevance:
+		return q.hasText()
+	}
+	return false
+}
+
+// sortKey is the order the list is actually in under q: relevance whenever
+// it is ranked, and last activity for an order that needs a search without one.
+func (m model) sortKey(q searchQuery) sortOrder {
+	switch {
+	case m.ranked(q):
+		return sortRelevance
+	case m.sortBy.needsQuery() && !q.hasText():
+		return sortRecent
+	}
+	return m.sortBy
+}
+
+// rank orders m.filtered under q by sortKey (scoring it when ranked), ties
+// newest first. Unreversed sortRecent keeps the load order.
+func (m *model) rank(q searchQuery) {
+	key := m.sortKey(q)
+	if key == sortRecent && !m.sortReverse {
+		return
+	}
+	var bm *bm25
+	if key == sortRelevance && !q.ranked() {
+		bm = newBM25(q, m.items)
+	}
+	// Sort keys are computed once per item rather than per comparison.
+	type keyed struct {
+		item listItem
+		num  float64
+		str  string
+	}
+	rows := make([]keyed, len(m.filtered))
+	for i, item := range m.filtered {
+		r := &rows[i]
+		switch key {
+		case sortRelevance:
+			if bm != nil { // fuzzy scores are kept from the filter pass
+				item.score = bm.score(item)
+			}
+			r.num = item.score
+		case sortRecent:
+			r.str = item.conv.LastTimestamp
+		case sortStarted:
+			r.str = item.conv.FirstTimestamp
+		case sortSize:
+			r.num = float64(item.conv.Size)
+		case sortMsgs:
+			r.num = float64(item.conv.messageCount())
+		case sortHits:
+			r.num = float64(m.hitCount(item))
+		case sortTitle:
+			r.str = fold(getTopic(item.conv))
+		}
+		r.item = item
+	}
+	asc := key == sortTitle
+	if m.sortReverse {
+		asc = !asc
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		c := cmp.Compare(a.num, b.num)
+		if c == 0 {
+			c = strings.Compare(a.str, b.str)
+		}
+		if c != 0 {
+			return (c < 0) == asc
+		}
+		return a.item.conv.LastTimestamp > b.item.conv.LastTimestamp
+	})
+	for i, r := range rows {
+		m.filtered[i] = r.item
+	}
+}
+
+// sortArrow is the header arrow for the list's direction under sortKey.
+func (m model) sortArrow(key sortOrder) string {
+	if (key == sortTitle) != m.sortReverse {
+		return "↑"
+	}
+	return "↓"
+}
+
+// resort reorders every match after a sort change (narrowing would keep the
+// old order) and stays on the same conversation.
+func (m *model) resort() {
+	id := m.selectedID()
+	m.lastQuery = nil
+	m.updateFilter()
+	m.selectID(id)
+}
+
+// selectedID is the SessionID under the cursor ("" for an empty list).
+func (m model) selectedID() string {
+	if len(m.filtered) == 0 {
+		return ""
+	}
+	return m.filtered[m.cursor].conv.SessionID
+}
+
+// selectID moves the cursor to the conversation with the given SessionID,
+// reporting whether it is in the filtered list.
+func (m *model) selectID(id string) bool {
+	for i, item := range m.filtered {
+		if item.conv.SessionID == id {
+			m.cursor = i
+			return true
+		}
+	}
+	return false
+}
+
 func (m model) Init() tea.Cmd {
 	return textinput.Blink
 }
@@ -225,20 +570,62 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
-		// Calculate visible list height
-		m.listHeight = m.height * 30 / 100
-		if m.listHeight < 3 {
-			m.listHeight = 3
+		// The preview wraps to its pane, so a resize changes its length.
+		m.relayout()
+		if m.viewer != nil {
+			m.viewer.display.width = msg.Width
+			m.viewer.render()
+			m.viewer.scroll = min(m.viewer.scroll, m.viewer.maxScroll(m.transcriptHeight()))
 		}
 		// Clear so a shrink doesn't leave wider stale rows behind.
 		return m, tea.ClearScreen
 
+	case convsChangedMsg:
+		m.applyChanges(msg.updated, msg.removed)
+		return m, nil
+
+	case loadProgressMsg:
+		if msg.err != nil {
+			m.loading = false
+			m.errorMsg = fmt.Sprintf("Loading conversations failed: %v", msg.err)
+			return m, nil
+		}
+		m.loadDone, m.loadTotal = msg.done, msg.total
+		m.loading = msg.done < msg.total
+		// A file the watcher already delivered is at least as fresh as the
+		// loader's copy, which may have been parsed before the latest write.
+		present := make(map[string]bool, len(m.items))
+		for _, item := range m.items {
+			present[item.conv.FilePath] = true
+		}
+		var fresh []listItem
+		for _, item := range msg.items {
+			if !present[item.conv.FilePath] {
+				fresh = append(fresh, item)
+			}
+		}
+		if len(fresh) > 0 {
+			m.applyChanges(fresh, nil)
+		}
+		return m, nil
+
 	case tea.KeyMsg:
+		if m.viewer != nil {
+			return m.updateTranscript(msg)
+		}
+		if m.facets.focused && !m.confirmDelete && !m.confirmPrune && !m.confirmExport {
+			return m.updateSidebar(msg)
+		}
+
 		// Handle delete confirmation mode
 		if m.confirmDelete {
 			switch msg.String() {
 			case "y", "Y":
-				m.deleteConversation()
+				if m.batch {
+					m.deleteMarked()
+				} else {
+					m.deleteConversation()
+				}
 				return m, nil
 			case "n", "N", "esc":
 				m.confirmDelete = false
@@ -251,7 +638,11 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.confirmPrune {
 			switch msg.String() {
 			case "y", "Y":
-				m.pruneConversation()
+				if m.batch {
+					m.pruneMarked()
+				} else {
+					m.pruneConversation()
+				}
 				return m, nil
 			case "n", "N", "esc":
 				m.confirmPrune = false
@@ -260,6 +651,19 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil // Ignore all other keys
 		}
 
+		// Handle export confirmation mode
+		if m.confirmExport {
+			switch msg.String() {
+			case "y", "Y":
+				m.exportConversations()
+				return m, nil
+			case "n", "N", "esc":
+				m.confirmExport, m.exports = false, nil
+				return m, nil
+			}
+			return m, nil // Ignore all other keys
+		}
+
 		// Clear error message on any keypress in normal mode
 		if m.errorMsg != "" {
 			m.errorMsg = ""
@@ -277,15 +681,46 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.quitting = true
 			return m, tea.Quit
 
-		case "ctrl+d":
+		case "tab":
 			if len(m.filtered) > 0 {
-				m.confirmDelete = true
+				m.toggleMark(m.filtered[m.cursor].conv.SessionID)
+				if m.cursor < len(m.filtered)-1 {
+					m.cursor++
+					m.previewScroll = 0
+				}
+			}
+			return m, nil
+
+		case "alt+a":
+			m.markAll()
+			return m, nil
+
+		case "ctrl+d":
+			// With marked conversations, Ctrl+D deletes those rather than the
+			// one under the cursor.
+			if len(m.marked) > 0 {
+				m.confirmDelete, m.batch = true, true
+			} else if len(m.filtered) > 0 {
+				m.confirmDelete, m.batch = true, false
 				m.deleteIndex = m.cursor
 			}
 			return m, nil
 
 		case "ctrl+r":
-			if len(m.filtered) > 0 {
+			if len(m.marked) > 0 {
+				// ponytail: measures every marked file now, as for one below.
+				var saved int64
+				for _, conv := range m.markedConvs() {
+					st, err := pruneFile(conv.FilePath, false, pruneOpts{dropSnapshots: true, stripToolResults: true})
+					if err != nil {
+						m.errorMsg = fmt.Sprintf("Prune preview failed: %v", err)
+						return m, nil
+					}
+					saved += st.bytesIn - st.bytesOut
+				}
+				m.confirmPrune, m.batch = true, true
+				m.pruneSaved = saved
+			} else if len(m.filtered) > 0 {
 				// Measure the projected saving so the prompt can show it.
 				// ponytail: reads the file once now (and again on confirm) - a
 				// multi-GB file briefly blocks, acceptable for a manual action.
@@ -294,12 +729,24 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.errorMsg = fmt.Sprintf("Prune preview failed: %v", err)
 					return m, nil
 				}
-				m.confirmPrune = true
+				m.confirmPrune, m.batch = true, false
 				m.pruneIndex = m.cursor
 				m.pruneSaved = st.bytesIn - st.bytesOut
 			}
 			return m, nil
 
+		case "ctrl+x":
+			// Export takes its conversations now, so live updates can't shift
+			// it to another one.
+			if len(m.marked) > 0 {
+				m.confirmExport, m.batch = true, true
+				m.exports = m.markedConvs()
+			} else if len(m.filtered) > 0 {
+				m.confirmExport, m.batch = true, false
+				m.exports = []Conversation{m.filtered[m.cursor].conv}
+			}
+			return m, nil
+
 		case "up", "ctrl+p":
 			if m.cursor > 0 {
 				m.cursor--
@@ -322,10 +769,110 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.previewScroll = min(m.previewScroll+10, m.maxPreviewScroll())
 			return m, nil
 
+		case "ctrl+o":
+			m.openTranscript()
+			return m, nil
+
+		case "alt+n":
+			m.jumpToMatch(1)
+			return m, nil
+
+		case "alt+p":
+			m.jumpToMatch(-1)
+			return m, nil
+
 		case "ctrl+u":
 			m.textInput.SetValue("")
 			m.updateFilter()
 			return m, nil
+
+		case "alt+z":
+			m.opts.fuzzy = !m.opts.fuzzy
+			m.updateFilter()
+			return m, nil
+
+		case "alt+r":
+			m.opts.regex = !m.opts.regex
+			m.updateFilter()
+			return m, nil
+
+		case "alt+t":
+			m.opts.tools = !m.opts.tools
+			m.updateFilter()
+			return m, nil
+
+		case "alt+c":
+			m.opts.caseMode = (m.opts.caseMode + 1) % 3
+			m.updateFilter()
+			return m, nil
+
+		case "alt+k":
+			m.opts.thinking = !m.opts.thinking
+			m.updateFilter()
+			return m, nil
+
+		case "alt+e":
+			m.display.expandThinking = !m.display.expandThinking
+			m.previewScroll = min(m.previewScroll, m.maxPreviewScroll())
+			return m, nil
+
+		case "alt+m":
+			m.display.raw = !m.display.raw
+			m.previewScroll = min(m.previewScroll, m.maxPreviewScroll())
+			return m, nil
+
+		case "alt+g":
+			// Open the facet sidebar, or return to it.
+			if !m.facets.open {
+				m.facets.open = true
+				m.relayout()
+				m.resort()
+			}
+			m.facets.focused = true
+			return m, tea.ClearScreen
+
+		case "alt+h":
+			if len(m.scope.dirs) > 0 {
+				m.here = !m.here
+				m.resort()
+			}
+			return m, nil
+
+		case "alt+v":
+			m.showBranch = !m.showBranch
+			return m, nil
+
+		case "alt+l":
+			m.layout = (m.layout + 1) % 3
+			m.listPct = 0 // each layout starts from its own default split
+			m.relayout()
+			return m, tea.ClearScreen
+
+		case "alt+=", "alt++", "alt+-":
+			step := listPctStep
+			if msg.String() == "alt+-" {
+				step = -step
+			}
+			m.listPct = max(listPctMin, min(listPctMax, m.listPercent()+step))
+			m.relayout()
+			return m, tea.ClearScreen
+
+		case "ctrl+s":
+			// Cycle the sort order, skipping those that need a search.
+			for {
+				m.sortBy = (m.sortBy + 1) % numSortOrders
+				if !m.sortBy.needsQuery() || m.query.hasText() {
+					break
+				}
+			}
+			m.sortReverse = false
+			m.resort()
+			return m, nil
+
+		case "alt+s":
+			m.sortReverse = !m.sortReverse
+			m.resort()
+			return m, nil
 		}
 	}
 
@@ -343,6 +890,9 @@ func (m model) View() string {
 	if m.width == 0 || m.height == 0 {
 		return "Loading..."
 	}
+	if m.viewer != nil {
+		return m.viewTranscript()
+	}
 
 	var b strings.Builder
 
@@ -352,7 +902,7 @@ func (m model) View() string {
 	// Title line with help right-aligned
 	title := fmt.Sprintf("ccs · claude code search · %s", version)
 	help := "Resume:Enter Delete:Ctrl+D Prune:Ctrl+R Scroll:Ctrl+J/K Exit:Esc"
-	titlePadding := tableWidth - 2 - len(title) - len(help)
+	titlePadding := tableWidth - 2 - runewidth.StringWidth(title) - len(help)
 	if titlePadding < 1 {
 		titlePadding = 1
 	}
@@ -362,7 +912,32 @@ func (m model) View() string {
 	// Search line or delete confirmation
 	var sections []string
 	var inputSection string
-	if m.confirmPrune {
+	if m.confirmPrune && m.batch {
+		size := m.markedSize()
+		inputSection = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("214")). // Amber
+			Render(fmt.Sprintf("Prune %d marked conversations? %s -> %s, saves %s (keeps dialogue). [y/N]",
+				len(m.marked), formatBytes(size), formatBytes(size-m.pruneSaved), formatBytes(m.pruneSaved)))
+		sections = append(sections, "  "+inputSection)
+	} else if m.confirmExport {
+		var size int64
+		for _, conv := range m.exports {
+			size += conv.Size
+		}
+		what := fmt.Sprintf("%d marked conversations", len(m.exports))
+		if !m.batch {
+			what = fmt.Sprintf("\"%s\"", truncate(getTopic(m.exports[0]), 32))
+		}
+		inputSection = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("39")). // Blue
+			Render(fmt.Sprintf("Export %s (%s) to %s as Markdown? [y/N]", what, formatBytes(size), m.exportDir))
+		sections = append(sections, "  "+inputSection)
+	} else if m.confirmDelete && m.batch {
+		inputSection = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("196")). // Red
+			Render(fmt.Sprintf("Delete %d marked conversations (%s)? [y/N]", len(m.marked), formatBytes(m.markedSize())))
+		sections = append(sections, "  "+inputSection)
+	} else if m.confirmPrune {
 		conv := m.filtered[m.pruneIndex].conv
 		inputSection = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("214")). // Amber
@@ -377,6 +952,15 @@ func (m model) View() string {
 		sections = append(sections, "  "+inputSection)
 	} else {
 		count := fmt.Sprintf("(%d/%d)", len(m.filtered), len(m.items))
+		if modes := m.modeLabels(); modes != "" {
+			count = modes + "  " + count
+		}
+		if len(m.marked) > 0 {
+			count = fmt.Sprintf("%d marked, %s  %s", len(m.marked), formatBytes(m.markedSize()), count)
+		}
+		if m.loading {
+			count = fmt.Sprintf("loaded %d/%d files  %s", m.loadDone, m.loadTotal, count)
+		}
 		searchPadding := tableWidth - 2 - 2 - 40 - len(count) - 1 // 2 for indent, 2 for "> ", 40 for textInput, -1 to shift left
 		if searchPadding < 1 {
 			searchPadding = 1
@@ -385,6 +969,12 @@ func (m model) View() string {
 		sections = append(sections, inputSection)
 	}
 
+	// Show an invalid query inline; the list keeps its last valid results.
+	if m.query.err != nil && !m.confirmDelete && !m.confirmPrune && !m.confirmExport {
+		errorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+		sections = append(sections, "  "+errorStyle.Render(m.query.err.Error()))
+	}
+
 	// Show error message if set
 	if m.errorMsg != "" {
 		errorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
@@ -394,20 +984,35 @@ func (m model) View() string {
 	b.WriteString(strings.Join(sections, "\n"))
 	b.WriteString("\n\n")
 
-	// Calculate heights
-	listHeight := m.height * 30 / 100
-	if listHeight < 3 {
-		listHeight = 3
-	}
-	previewHeight := m.height - listHeight - 6 // 6 for title + search + blank + header + borders
-
 	// Column headers
-	b.WriteString(fmt.Sprintf("  \033[90m%-*s  %-*s  %-*s  %*s  %*s  %*s\033[0m\n",
-		colDate, "DATE", colProject, "PROJECT", m.topicColWidth(), "TOPIC", colMsgs, "MSGS", colHits, "HITS", colSize, "SIZE"))
-	b.WriteString(strings.Repeat("─", m.width))
-	b.WriteString("\n")
+	listWidth := m.listWidth()
+	var list []string
+	// The column the list is sorted by carries an arrow for its direction.
+	key := m.sortKey(m.currentQuery())
+	label := func(name string, by sortOrder) string {
+		if key == by {
+			return name + m.sortArrow(key)
+		}
+		return name
+	}
+	date := label("DATE", sortRecent)
+	if key == sortStarted {
+		date = label("STARTED", sortStarted)
+	}
+	scoreHeader := ""
+	if m.showScore() {
+		scoreHeader = fmt.Sprintf("%*s  ", colScore, label("SCORE", sortRelevance))
+	}
+	branchHeader := ""
+	if m.showBranch {
+		branchHeader = fmt.Sprintf("%-*s  ", colBranch, "BRANCH")
+	}
+	list = append(list, fmt.Sprintf("  \033[90m%-*s  %-*s  %s%-*s  %*s  %*s  %s%*s\033[0m",
+		colDate, date, colProject, "PROJECT", branchHeader, m.topicColWidth(), label("TOPIC", sortTitle),
+		colMsgs, label("MSGS", sortMsgs), colHits, label("HITS", sortHits), scoreHeader, colSize, label("SIZE", sortSize)))
+	list = append(list, strings.Repeat("─", listWidth))
 
-	visibleItems := listHeight
+	visibleItems := m.listRows()
 	start := 0
 	if m.cursor >= visibleItems {
 		start = m.cursor - visibleItems + 1
@@ -418,50 +1023,214 @@ func (m model) View() string {
 		isSelected := i == m.cursor
 		line := m.formatListItem(item, isSelected)
 
+		// The indent holds the cursor and the mark (Tab).
+		mark := " "
+		if m.marked[item.conv.SessionID] {
+			mark = "*"
+		}
 		if isSelected {
 			// Pad to full width for selection highlight
-			line = padRight("> "+line, m.width)
-			b.WriteString(selectedStyle.Render(line))
+			line = padRight(">"+mark+line, listWidth)
+			list = append(list, selectedStyle.Render(line))
+		} else if mark != " " {
+			list = append(list, " \033[1;35m*\033[0m"+line)
 		} else {
-			b.WriteString("  " + line)
+			list = append(list, "  "+line)
 		}
-		b.WriteString("\n")
 	}
 
 	// Fill remaining list space
-	for i := len(m.filtered) - start; i < visibleItems; i++ {
-		b.WriteString("\n")
+	fill := len(m.filtered) - start
+	if len(m.items) == 0 && !m.loading {
+		list = append(list, "  \033[90mNo conversations found\033[0m")
+		fill++
+	}
+	for i := fill; i < visibleItems; i++ {
+		list = append(list, "")
 	}
 
-	// Preview section
-	b.WriteString(strings.Repeat("─", m.width))
-	b.WriteString("\n")
-
+	var preview []string
 	if len(m.filtered) > 0 {
-		preview := m.renderPreview(m.filtered[m.cursor], previewHeight)
-		b.WriteString(preview)
+		preview = strings.Split(m.renderPreview(m.filtered[m.cursor], m.previewHeight()), "\n")
+	}
+
+	// The facet sidebar runs down the left of the list.
+	if m.facets.open {
+		if m.sideBySide() {
+			for len(list) < m.previewHeight() {
+				list = append(list, "")
+			}
+		}
+		side := m.renderSidebar(len(list))
+		for i := range list {
+			list[i] = fitWidth(side[i], sidebarWidth) + "\033[90m│\033[0m" + list[i]
+		}
 	}
 
+	if !m.sideBySide() {
+		b.WriteString(strings.Join(list, "\n"))
+		b.WriteString("\n")
+		// Preview section
+		b.WriteString(strings.Repeat("─", m.width))
+		b.WriteString("\n")
+		b.WriteString(strings.Join(preview, "\n"))
+		return b.String()
+	}
+
+	// Side by side: each screen row is a list line, a rule and a preview line.
+	rows := make([]string, m.previewHeight())
+	for i := range rows {
+		left, right := "", ""
+		if i < len(list) {
+			left = list[i]
+		}
+		if i < len(preview) {
+			right = preview[i]
+		}
+		rows[i] = fitWidth(left, m.sidebarCols()+listWidth) + "\033[90m│\033[0m" + right
+	}
+	b.WriteString(strings.Join(rows, "\n"))
 	return b.String()
 }
 
+// modeLabels names the active search modes for the search line.
+func (m model) modeLabels() string {
+	var modes []string
+	if m.here {
+		modes = append(modes, "here:"+m.scope.name)
+	}
+	if m.opts.fuzzy {
+		modes = append(modes, "fuzzy")
+	}
+	if m.opts.regex {
+		modes = append(modes, "regex")
+	}
+	if m.opts.tools {
+		modes = append(modes, "tools")
+	}
+	if m.opts.thinking {
+		modes = append(modes, "thinking")
+	}
+	if m.opts.caseMode != caseSmart {
+		modes = append(modes, caseModeNames[m.opts.caseMode])
+	}
+	if m.sortBy == sortRelevance {
+		modes = append(modes, "relevance")
+	}
+	return strings.Join(modes, " ")
+}
+
+// layoutMode is how the list and the preview share the screen (Alt+L).
+type layoutMode int
+
+const (
+	layoutAuto    layoutMode = iota // side by side from sideBySideWidth, else stacked
+	layoutStacked                   // list above the preview
+	layoutSide                      // list left, preview right
+)
+
+var layoutNames = map[layoutMode]string{layoutAuto: "auto", layoutStacked: "stacked", layoutSide: "side"}
+
+// sideBySideWidth is the terminal width from which the auto layout puts the
+// preview beside the list rather than below it.
+const sideBySideWidth = 160
+
+// The list pane's share of the screen, in percent of the height when stacked
+// and of the width side by side. Alt+= and Alt+- move it by listPctStep.
+const (
+	listPctStacked = 30
+	listPctSide    = 50
+	listPctMin     = 15
+	listPctMax     = 85
+	listPctStep    = 5
+)
+
+// sideBySide reports whether the preview is beside the list.
+func (m model) sideBySide() bool {
+	return m.layout == layoutSide || m.layout == layoutAuto && m.width >= sideBySideWidth
+}
+
+// listPercent is the list pane's share of the screen for the current layout.
+func (m model) listPercent() int {
+	if m.listPct > 0 {
+		return m.listPct
+	}
+	if m.sideBySide() {
+		return listPctSide
+	}
+	return listPctStacked
+}
+
+// listWidth is the width of the list pane.
+func (m model) listWidth() int {
+	w := max(0, m.width-m.sidebarCols()) // the sidebar can outgrow a narrow terminal
+	if !m.sideBySide() {
+		return w
+	}
+	return w * m.listPercent() / 100
+}
+
+// previewWidth is the width of the preview pane, which its lines wrap to.
+func (m model) previewWidth() int {
+	if !m.sideBySide() {
+		return m.width
+	}
+	return max(1, m.width-m.sidebarCols()-m.listWidth()-1) // a "│" separates the panes
+}
+
+// listRows is the number of conversations the list shows.
+func (m model) listRows() int {
+	if m.sideBySide() {
+		return max(3, m.height-5) // below title, search, blank, header and rule
+	}
+	return max(3, m.height*m.listPercent()/100)
+}
+
+// previewHeight is the number of lines in the preview pane.
+func (m model) previewHeight() int {
+	if m.sideBySide() {
+		return max(1, m.height-3) // below title, search and blank
+	}
+	return m.height - m.listRows() - 6 // 6 for title + search + blank + header + borders
+}
+
+// relayout refits the preview after the terminal or the panes change size.
+func (m *model) relayout() {
+	m.display.width = m.previewWidth()
+	m.previewScroll = min(m.previewScroll, m.maxPreviewScroll())
+}
+
 // Fixed list column widths. TOPIC is the flex column - it absorbs the rest of
-// the terminal width (see topicColWidth).
+// the list pane's width (see topicColWidth).
 const (
 	colDate    = 16
 	colProject = 22
+	colBranch  = 16 // only with showBranch
 	colMsgs    = 5
-	colHits    = 4
+	colHits    = 5 // "HITS↓"
+	colScore   = 6 // "SCORE↓"; only while the list is ranked (see showScore)
 	colSize    = 6
 	colGap     = 2 // spaces between columns
 	listIndent = 2 // leading "  " / "> " on each row
 	numGaps    = 5
 )
 
-// topicColWidth flexes the TOPIC column to fill the terminal width.
+// showScore reports whether the list has a SCORE column - while it is
+// ordered by score.
+func (m model) showScore() bool {
+	return m.ranked(m.currentQuery())
+}
+
+// topicColWidth flexes the TOPIC column to fill the list pane's width.
 func (m model) topicColWidth() int {
 	used := listIndent + colDate + colProject + colMsgs + colHits + colSize + numGaps*colGap
-	if w := m.width - used; w > 10 {
+	if m.showScore() {
+		used += colScore + colGap
+	}
+	if m.showBranch {
+		used += colBranch + colGap
+	}
+	if w := m.listWidth() - used; w > 10 {
 		return w
 	}
 	return 10
@@ -469,54 +1238,100 @@ func (m model) topicColWidth() int {
 
 func (m model) formatListItem(item listItem, selected bool) string {
 	ts := formatTimestamp(item.conv.LastTimestamp)
-	project := item.conv.Cwd
+	if m.sortKey(m.currentQuery()) == sortStarted { // the DATE column is STARTED
+		ts = formatTimestamp(item.conv.FirstTimestamp)
+	}
+	// Worktrees show as the repository they belong to.
+	project := item.conv.project()
 	if idx := strings.LastIndex(project, "/"); idx >= 0 {
 		project = project[idx+1:]
 	}
-	project = truncate(project, colProject)
+	// Columns are sized in terminal cells, so CJK and emoji rows line up.
+	project = padRight(truncate(project, colProject), colProject)
+
+	// Branch, when shown. The "+N" of a session on several branches is kept
+	// when the name is cut.
+	branch := ""
+	if m.showBranch {
+		name, others := item.conv.latestBranch()
+		more := ""
+		if others > 0 {
+			more = fmt.Sprintf(" +%d", others)
+		}
+		branch = truncate(name, colBranch-len(more)) + more
+		branch = padRight(branch, colBranch) + "  "
+		if !selected {
+			branch = "\033[32m" + branch + "\033[0m"
+		}
+	}
 
 	// Mark only user-set custom titles. Claude auto-generates an ai-title for
 	// almost every session, so marking any title would flag nearly every row;
-	// the ✎ should mean "you named this". ponytail: the glyph is ambiguous-width,
-	// so a marked row may sit one cell narrow on CJK-width terminals - cosmetic
-	// only, truncate is rune-safe.
+	// the ✎ should mean "you named this". It is measured like any other rune,
+	// following the terminal's ambiguous-width setting as go-runewidth sees it.
 	topic := getTopic(item.conv)
 	if item.conv.IsCustomTitle {
 		topic = "✎ " + topic
 	}
 	tw := m.topicColWidth()
-	topic = truncate(topic, tw)
+	topic = padRight(truncate(topic, tw), tw)
 
 	// Message count
-	msgs := len(item.conv.Messages)
+	msgs := item.conv.messageCount()
 
 	// Number of messages containing the query (memoised per query).
 	hits := m.hitCount(item)
 
 	size := formatBytes(item.conv.Size)
 
-	// Format: date | project | topic | msgs | hits | size (aligned columns)
+	// Rank score, only while the list is ordered by it.
+	score := ""
+	if m.showScore() {
+		score = fmt.Sprintf("%*s  ", colScore, formatScore(item.score))
+		if !selected {
+			score = "\033[32m" + score + "\033[0m"
+		}
+	}
+
+	// Format: date | project | [branch] | topic | msgs | hits | [score] | size (aligned columns)
 	if selected {
-		return fmt.Sprintf("%-*s  %-*s  %-*s  %*d  %*d  %*s",
-			colDate, ts, colProject, project, tw, topic, colMsgs, msgs, colHits, hits, colSize, size)
+		return fmt.Sprintf("%-*s  %s  %s%s  %*d  %*d  %s%*s",
+			colDate, ts, project, branch, topic, colMsgs, msgs, colHits, hits, score, colSize, size)
+	}
+	return fmt.Sprintf("\033[90m%-*s\033[0m  \033[1;33m%s\033[0m  %s%s  %*d  \033[36m%*d\033[0m  %s\033[35m%*s\033[0m",
+		colDate, ts, project, branch, topic, colMsgs, msgs, colHits, hits, score, colSize, size)
+}
+
+// formatScore fits a rank score in the SCORE column: one decimal for the small
+// BM25 values, whole numbers for fuzzy scores.
+func formatScore(score float64) string {
+	if score >= 100 {
+		return strconv.FormatFloat(score, 'f', 0, 64)
 	}
-	return fmt.Sprintf("\033[90m%-*s\033[0m  \033[1;33m%-*s\033[0m  %-*s  %*d  \033[36m%*d\033[0m  \033[35m%*s\033[0m",
-		colDate, ts, colProject, project, tw, topic, colMsgs, msgs, colHits, hits, colSize, size)
+	return strconv.FormatFloat(score, 'f', 1, 64)
 }
 
 // buildPreviewLines builds the scrollable message lines of a conversation
 // preview (everything below the fixed header). Shared by renderPreview and
 // maxPreviewScroll so the render and the scroll-clamp can never disagree on how
 // far the preview can scroll.
-func buildPreviewLines(conv Conversation, query string) []string {
+func buildPreviewLines(conv Conversation, q searchQuery, po previewOpts) []string {
 	var msgLines []string
 
-	// Find messages containing the query
-	queryLower := strings.ToLower(query)
+	// Tool calls and results only appear when they are being searched;
+	// thinking always does, collapsed unless expanded or matching.
+	var msgs []Message
+	for _, msg := range conv.Messages {
+		if q.searches(msg) || msg.Kind == kindThinking {
+			msgs = append(msgs, msg)
+		}
+	}
+
+	// Find messages matching the query
 	matchSet := make(map[int]bool)
-	if query != "" {
-		for i, msg := range conv.Messages {
-			if strings.Contains(strings.ToLower(msg.Text), queryLower) {
+	if q.hasText() {
+		for i, msg := range msgs {
+			if q.matchMessage(msg) {
 				matchSet[i] = true
 			}
 		}
@@ -525,12 +1340,19 @@ func buildPreviewLines(conv Conversation, query string) []string {
 	// Build set of indices to show
 	showSet := make(map[int]bool)
 
-	// Always show first 2 and last 2 messages
-	for i := 0; i < 2 && i < len(conv.Messages); i++ {
-		showSet[i] = true
+	// Always show the first 2 and last 2 messages - not counting collapsed
+	// thinking, which would crowd the dialogue out of those slots.
+	collapsed := func(i int) bool {
+		return msgs[i].Kind == kindThinking && !po.expandThinking && !matchSet[i]
+	}
+	var slots []int
+	for i := range msgs {
+		if !collapsed(i) {
+			slots = append(slots, i)
+		}
 	}
-	for i := len(conv.Messages) - 2; i < len(conv.Messages); i++ {
-		if i >= 0 {
+	for k, i := range slots {
+		if k < 2 || k >= len(slots)-2 {
 			showSet[i] = true
 		}
 	}
@@ -541,14 +1363,30 @@ func buildPreviewLines(conv Conversation, query string) []string {
 			showSet[idx-1] = true
 		}
 		showSet[idx] = true
-		if idx < len(conv.Messages)-1 {
+		if idx < len(msgs)-1 {
 			showSet[idx+1] = true
 		}
 	}
 
+	// Collapsed thinking between shown messages is a line of its own, no
+	// longer than the gap marker it would otherwise leave.
+	for i := range msgs {
+		if !collapsed(i) || showSet[i] {
+			continue
+		}
+		prev, next := i-1, i+1
+		for prev >= 0 && collapsed(prev) {
+			prev--
+		}
+		for next < len(msgs) && collapsed(next) {
+			next++
+		}
+		showSet[i] = (prev < 0 || showSet[prev]) && (next == len(msgs) || showSet[next])
+	}
+
 	// Display messages with gaps
 	lastShown := -1
-	for i := 0; i < len(conv.Messages); i++ {
+	for i := 0; i < len(msgs); i++ {
 		if !showSet[i] {
 			continue
 		}
@@ -562,42 +1400,262 @@ func buildPreviewLines(conv Conversation, query string) []string {
 			msgLines = append(msgLines, "")
 		}
 
-		msg := conv.Messages[i]
-		ts := formatTimestamp(msg.Ts)
-		var prefix string
-		if matchSet[i] {
-			if msg.Role == "user" {
-				prefix = fmt.Sprintf("\033[1;32m>>> %s User:\033[0m", ts) // Bold green
-			} else {
-				prefix = fmt.Sprintf("\033[1;34m>>> %s Claude:\033[0m", ts) // Bold blue
-			}
+		msgLines = append(msgLines, messageLines(msgs[i], q, po, matchSet[i], false)...)
+		msgLines = append(msgLines, "")
+
+		lastShown = i
+	}
+
+	if lastShown < len(msgs)-1 {
+		remaining := len(msgs) - lastShown - 1
+		msgLines = append(msgLines, fmt.Sprintf("\033[90m    ... %d more messages\033[0m", remaining))
+	}
+
+	return msgLines
+}
+
+// messageLines renders one message: a prefix line (role and time, ">>>" if
+// matched) and its text. In the preview (full false) long text is cut to the
+// head or, if it has matches, to excerpts; the transcript viewer (full true)
+// shows dialogue whole and collapses tool results unless they have matches.
+// Thinking is collapsed in both unless expanded or it has matches.
+func messageLines(msg Message, q searchQuery, po previewOpts, matched, full bool) []string {
+	ts := formatTimestamp(msg.Ts)
+	text := msg.Text
+	// A match with nothing to mark - a zero-width regex such as /^/, or
+	// qualifiers alone - opens nothing up: the message shows as if it hadn't
+	// matched, behind its ">>>".
+	var tr []rune
+	var marked []bool
+	open := false
+	if matched {
+		tr, marked = q.marks(text)
+		open = slices.Contains(marked, true)
+	}
+	var prefix string
+	if msg.Kind == kindThinking {
+		marker := "   "
+		if matched {
+			marker = ">>>"
+		}
+		if po.expandThinking || open {
+			prefix = fmt.Sprintf("\033[90m%s %s Thinking:\033[0m", marker, ts) // Gray
 		} else {
-			if msg.Role == "user" {
-				prefix = fmt.Sprintf("\033[32m    %s User:\033[0m", ts) // Green
+			prefix = fmt.Sprintf("\033[90m%s %s Thinking (%d lines, Alt+E to expand)\033[0m", marker, ts, strings.Count(text, "\n")+1)
+			text = ""
+		}
+	} else if !msg.dialogue() {
+		// Tool calls show their summary inline: Tool: Bash `go test ./...`
+		label := "Result:"
+		if msg.Kind == kindToolUse {
+			label = "Tool: " + msg.Tool
+		} else if full && !open {
+			label = fmt.Sprintf("Result (%d lines)", strings.Count(text, "\n")+1)
+		}
+		if matched {
+			prefix = fmt.Sprintf("\033[1;35m>>> %s %s\033[0m", ts, label) // Bold magenta
+		} else {
+			prefix = fmt.Sprintf("\033[35m    %s %s\033[0m", ts, label) // Magenta
+		}
+		if msg.Kind == kindToolUse && text != "" {
+			summary, rest, _ := strings.Cut(text, "\n")
+			prefix += " `" + q.highlight(truncate(summary, 200)) + "`"
+			text = rest
+			if i := slices.Index(tr, '\n'); i >= 0 {
+				tr, marked = tr[i+1:], marked[i+1:]
 			} else {
-				prefix = fmt.Sprintf("\033[34m    %s Claude:\033[0m", ts) // Blue
+				tr, marked = nil, nil
 			}
 		}
+		if full && !open {
+			text = ""
+		}
+	} else if matched {
+		if msg.Role == "user" {
+			prefix = fmt.Sprintf("\033[1;32m>>> %s User:\033[0m", ts) // Bold green
+		} else {
+			prefix = fmt.Sprintf("\033[1;34m>>> %s Claude:\033[0m", ts) // Bold blue
+		}
+	} else {
+		if msg.Role == "user" {
+			prefix = fmt.Sprintf("\033[32m    %s User:\033[0m", ts) // Green
+		} else {
+			prefix = fmt.Sprintf("\033[34m    %s Claude:\033[0m", ts) // Blue
+		}
+	}
 
-		msgLines = append(msgLines, prefix)
-		text := msg.Text
-		if r := []rune(text); len(r) > 500 {
-			text = string(r[:500]) + "... (truncated)" // slice on runes, not bytes
+	lines := []string{prefix}
+	var md *markdown // nil: raw
+	if !po.raw && (msg.dialogue() || msg.Kind == kindThinking) {
+		md = &markdown{}
+	}
+	if (open || full) && text != "" {
+		// Highlight the message as a whole, so long ones can be cut down
+		// to where the matches are.
+		if !open {
+			tr, marked = q.marks(text)
+		}
+		if marked == nil {
+			tr = []rune(text)
+			marked = make([]bool, len(tr))
+		}
+		if !full && len(tr) > previewMaxRunes {
+			if slices.Contains(marked, true) {
+				tr, marked = excerpt(tr, marked, po.contextRunes())
+			} else { // a tool call matched on its summary line only
+				tr = append(tr[:previewMaxRunes:previewMaxRunes], []rune("... (truncated)")...)
+				marked = append(marked[:previewMaxRunes:previewMaxRunes], make([]bool, len(tr)-previewMaxRunes)...)
+			}
+		}
+		for len(tr) > 0 {
+			end := slices.Index(tr, '\n')
+			if end < 0 {
+				end = len(tr)
+			}
+			lines = append(lines, "    "+md.line(tr[:end], marked[:end]))
+			tr, marked = tr[min(end+1, len(tr)):], marked[min(end+1, len(marked)):]
+		}
+	} else if text != "" || msg.dialogue() { // a collapsed block is just its prefix
+		if r := []rune(text); len(r) > previewMaxRunes {
+			text = string(r[:previewMaxRunes]) + "... (truncated)" // slice on runes, not bytes
 		}
 		for _, line := range strings.Split(text, "\n") {
-			msgLines = append(msgLines, "    "+highlight(line, query))
+			tr, marked := q.marks(line)
+			if marked == nil {
+				tr = []rune(line)
+			}
+			lines = append(lines, "    "+md.line(tr, marked))
 		}
-		msgLines = append(msgLines, "")
+	}
+	return wrapLines(lines, po.width)
+}
 
-		lastShown = i
+// contextRunes is the context width around a match in an excerpt.
+func (po previewOpts) contextRunes() int {
+	if po.context > 0 {
+		return po.context
 	}
+	return excerptContext
+}
 
-	if lastShown < len(conv.Messages)-1 {
-		remaining := len(conv.Messages) - lastShown - 1
-		msgLines = append(msgLines, fmt.Sprintf("\033[90m    ... %d more messages\033[0m", remaining))
+// excerpt cuts a long message down to its matches: each marked run with up to
+// context runes either side (overlapping windows merged), joined by "…".
+// Returns the excerpt's runes and marks.
+func excerpt(tr []rune, marked []bool, context int) ([]rune, []bool) {
+	type window struct{ start, end int }
+	var windows []window
+	extra := 0 // matches beyond excerptMaxWindows
+	for i := 0; i < len(tr); i++ {
+		if !marked[i] {
+			continue
+		}
+		j := i
+		for j < len(tr) && marked[j] {
+			j++
+		}
+		w := window{max(0, i-context), min(len(tr), j+context)}
+		switch n := len(windows); {
+		case n > 0 && w.start <= windows[n-1].end:
+			windows[n-1].end = w.end
+		case n == excerptMaxWindows:
+			extra++
+		default:
+			windows = append(windows, w)
+		}
+		i = j
 	}
 
-	return msgLines
+	var out []rune
+	var outMarks []bool
+	add := func(r []rune, m []bool) {
+		out = append(out, r...)
+		if m == nil {
+			m = make([]bool, len(r))
+		}
+		outMarks = append(outMarks, m...)
+	}
+	for k, w := range windows {
+		switch {
+		case k > 0:
+			add([]rune(" … "), nil)
+		case w.start > 0:
+			add([]rune("… "), nil)
+		}
+		add(tr[w.start:w.end], marked[w.start:w.end])
+	}
+	if last := windows[len(windows)-1]; last.end < len(tr) {
+		add([]rune(" …"), nil)
+	}
+	if extra > 0 {
+		add([]rune(fmt.Sprintf(" (%d more matches)", extra)), nil)
+	}
+	return out, outMarks
+}
+
+// matchContext is how many lines jumping to a match keeps above it, so the
+// message's role and timestamp stay in view.
+const matchContext = 1
+
+// matchLines returns the indexes of the preview lines holding a highlighted
+// match - the stops for Alt+N/Alt+P.
+func matchLines(lines []string) []int {
+	var out []int
+	for i, line := range lines {
+		if strings.Contains(line, highlightStart) {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// currentMatch is the 1-based number of the last match line at the top of a
+// preview scrolled to scroll (allowing for matchContext), or 0 if the view is
+// above the first match.
+func currentMatch(matches []int, scroll int) int {
+	k := 0
+	for _, line := range matches {
+		if line <= scroll+matchContext {
+			k++
+		}
+	}
+	return k
+}
+
+// jumpToMatch scrolls the preview to the next (dir 1) or previous (dir -1)
+// match line, wrapping around at either end.
+func (m *model) jumpToMatch(dir int) {
+	if scroll, ok := matchScroll(matchLines(m.previewLines()), m.previewScroll, dir); ok {
+		m.previewScroll = min(scroll, m.maxPreviewScroll())
+	}
+}
+
+// matchScroll is the scroll position that brings the next (dir 1) or previous
+// (dir -1) match line after scroll into view, wrapping around at either end.
+// ok is false if there are no matches.
+func matchScroll(matches []int, scroll, dir int) (int, bool) {
+	if len(matches) == 0 {
+		return 0, false
+	}
+	top := scroll + matchContext
+	target := -1
+	if dir > 0 {
+		target = matches[0]
+		for _, line := range matches {
+			if line > top {
+				target = line
+				break
+			}
+		}
+	} else {
+		target = matches[len(matches)-1]
+		for i := len(matches) - 1; i >= 0; i-- {
+			if matches[i] < top {
+				target = matches[i]
+				break
+			}
+		}
+	}
+	return max(0, target-matchContext), true
 }
 
 // maxPreviewScroll is the furthest the preview of the current selection can
@@ -610,20 +1668,36 @@ func (m model) maxPreviewScroll() int {
 }
 
 func (m model) renderPreview(item listItem, height int) string {
-	query := m.textInput.Value()
+	q := m.currentQuery()
 	conv := item.conv
 
 	// Fixed header (always visible)
 	var header []string
-	header = append(header, "\033[1;33mProject:\033[0m "+highlight(conv.Cwd, query))
+	project := "\033[1;33mProject:\033[0m " + q.highlight(conv.Cwd)
+	if conv.worktree() {
+		project += "  \033[90mworktree of " + filepath.Base(conv.repo) + "\033[0m"
+	}
+	header = append(header, project)
+	if len(conv.Branches) > 0 {
+		header = append(header, "\033[1;33mBranch:\033[0m  "+q.highlight(strings.Join(conv.Branches, ", ")))
+	}
 	if conv.Title != "" {
-		header = append(header, "\033[1;33mName:\033[0m    "+highlight(conv.Title, query))
+		header = append(header, "\033[1;33mName:\033[0m    "+q.highlight(conv.Title))
 	}
-	header = append(header, "\033[1;33mSession:\033[0m "+highlight(conv.SessionID, query))
-	header = append(header, "")
-
 	msgLines := m.previewLines() // memoised; item is always the selected conversation
 
+	session := "\033[1;33mSession:\033[0m " + q.highlight(conv.SessionID)
+	if matches := matchLines(msgLines); len(matches) > 0 {
+		pos := fmt.Sprintf("%d matches", len(matches))
+		if k := currentMatch(matches, m.previewScroll); k > 0 {
+			pos = fmt.Sprintf("match %d/%d", k, len(matches))
+		}
+		session += "  \033[90m" + pos + " · Alt+N/P\033[0m"
+	}
+	header = append(header, session)
+	header = wrapLines(header, m.display.width) // long paths and titles stay in the pane
+	header = append(header, "")
+
 	// Apply scroll to messages only (header stays fixed). Clamp locally for this
 	// render; the persisted m.previewScroll is bounded in Update via
 	// maxPreviewScroll (this method has a value receiver, so a write here would
@@ -641,216 +1715,2497 @@ func (m model) renderPreview(item listItem, height int) string {
 	return strings.Join(allLines, "\n")
 }
 
+// highlight marks the query's free-text matches in text.
 func highlight(text, query string) string {
-	if query == "" {
-		return text
+	return parseQuery(query).highlight(text)
+}
+
+// padRight cuts or pads s to exactly length terminal cells. A wide rune that
+// would straddle the edge is dropped and its cell padded instead.
+func padRight(s string, length int) string {
+	s = runewidth.Truncate(s, length, "")
+	return s + strings.Repeat(" ", length-runewidth.StringWidth(s))
+}
+
+// wrapLines soft-wraps rendered lines to width terminal cells (see wrapLine).
+func wrapLines(lines []string, width int) []string {
+	if width <= 0 {
+		return lines
 	}
-	tr := []rune(text)
-	lr := []rune(strings.ToLower(text))
-	queryLower := strings.ToLower(query)
-	qr := []rune(queryLower)
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		out = append(out, wrapLine(line, width)...)
+	}
+	return out
+}
 
-	// Match on runes so multibyte text (CJK, emoji) is never sliced mid-rune.
-	// ponytail: a handful of runes change length when lowercased (İ, Kelvin K),
-	// which breaks the lr/tr index alignment - bail to plain text rather than
-	// emit corrupted bytes. Highlighting those is not worth the complexity.
-	if len(lr) != len(tr) || len(qr) == 0 {
-		return text
+// wrapLine breaks a rendered line into segments of at most width terminal
+// cells, after a space where possible. Continuation segments keep the line's
+// indent, and SGR styles (colours, highlights) in effect at a break are reset
+// at the end of the segment and reopened at the start of the next, so every
+// segment renders correctly on its own.
+// ponytail: tabs and other control characters count as zero cells; tool
+// output containing them can still overrun the terminal.
+func wrapLine(line string, width int) []string {
+	if width <= 0 || ansiWidth(line) <= width {
+		return []string{line}
+	}
+	indent := len(line) - len(strings.TrimLeft(line, " "))
+	if indent > width/2 {
+		indent = 0
 	}
 
-	var result strings.Builder
-	for i := 0; i < len(tr); {
-		if i+len(qr) <= len(tr) && string(lr[i:i+len(qr)]) == queryLower {
-			// Yellow background, black text for highlight
-			result.WriteString("\033[43;30m")
-			result.WriteString(string(tr[i : i+len(qr)]))
-			result.WriteString("\033[0m")
-			i += len(qr)
-		} else {
-			result.WriteRune(tr[i])
-			i++
+	type token struct {
+		s   string
+		w   int // cells; 0 for escape sequences
+		esc bool
+	}
+	var (
+		out       []string
+		cur       []token
+		w         int
+		styles    []string // SGR sequences in effect
+		brk       = -1     // index in cur just after the last breakable space
+		brkStyles []string // styles in effect at brk
+		text      bool     // cur has non-space text past the indent
+	)
+	emit := func(toks []token, open []string) {
+		var b strings.Builder
+		for _, t := range toks {
+			b.WriteString(t.s)
 		}
+		if len(open) > 0 {
+			b.WriteString("\033[0m")
+		}
+		out = append(out, b.String())
+	}
+	restart := func(rest []token, open []string) {
+		next := make([]token, 0, indent+len(open)+len(rest))
+		for range indent {
+			next = append(next, token{s: " ", w: 1})
+		}
+		for _, s := range open {
+			if s == highlightStart {
+				s = highlightResume // not a match of its own (see matchLines)
+			}
+			next = append(next, token{s: s, esc: true})
+		}
+		w, text, brk = indent, false, -1
+		for _, t := range rest {
+			w += t.w
+			text = text || !t.esc
+		}
+		cur = append(next, rest...)
 	}
-	return result.String()
-}
 
-func padRight(s string, length int) string {
-	r := []rune(s)
-	if len(r) >= length {
-		return string(r[:length])
+	for i := 0; i < len(line); {
+		if n := escapeLen(line[i:]); n > 0 {
+			cur = append(cur, token{s: line[i : i+n], esc: true})
+			styles = applySGR(styles, line[i:i+n])
+			i += n
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(line[i:])
+		t := token{s: line[i : i+size], w: runewidth.RuneWidth(r)}
+		i += size
+		if w+t.w > width && text {
+			if r == ' ' { // break here, dropping the space
+				emit(cur, styles)
+				restart(nil, styles)
+				continue
+			}
+			if brk >= 0 {
+				emit(cur[:brk], brkStyles)
+				restart(slices.Clone(cur[brk:]), brkStyles)
+			} else {
+				emit(cur, styles)
+				restart(nil, styles)
+			}
+		}
+		cur = append(cur, t)
+		w += t.w
+		if r == ' ' {
+			if text {
+				brk, brkStyles = len(cur), slices.Clone(styles)
+			}
+		} else {
+			text = true
+		}
 	}
-	// ponytail: pads by rune count, not display width; CJK/emoji rows can still
-	// look a cell narrow. Swap in go-runewidth if column alignment matters.
-	return s + strings.Repeat(" ", length-len(r))
+	emit(cur, nil)
+	return out
 }
 
-// ============================================================================
-// Data loading (preserved from original)
-// ============================================================================
-
-// getProjectsDir returns the path to the Claude projects directory
-// Declared as a variable so it can be overridden in tests
-var getProjectsDir = func() string {
-	home, _ := os.UserHomeDir()
-	return filepath.Join(home, ".claude", "projects")
+// escapeLen is the length of the ANSI CSI sequence (ESC [ ... final byte) at
+// the start of s, or 0 if s doesn't start with one.
+func escapeLen(s string) int {
+	if !strings.HasPrefix(s, "\033[") {
+		return 0
+	}
+	for j := 2; j < len(s); j++ {
+		if c := s[j]; c >= 0x40 && c <= 0x7e {
+			return j + 1
+		} else if c < 0x20 || c > 0x3f {
+			return 0
+		}
+	}
+	return 0
 }
 
-func extractText(content json.RawMessage) string {
-	if len(content) == 0 {
-		return ""
+// applySGR updates the styles in effect after the escape sequence seq: a
+// reset clears them, any other SGR sequence adds to them.
+func applySGR(styles []string, seq string) []string {
+	switch {
+	case !strings.HasSuffix(seq, "m"):
+		return styles
+	case seq == "\033[0m" || seq == "\033[m":
+		return nil
 	}
+	return append(styles, seq)
+}
 
-	var str string
-	if err := json.Unmarshal(content, &str); err == nil {
-		return str
+// fitWidth cuts or pads a rendered line to exactly width terminal cells,
+// keeping its escape sequences.
+func fitWidth(s string, width int) string {
+	width = max(0, width)
+	w := 0
+	for i := 0; i < len(s); {
+		if n := escapeLen(s[i:]); n > 0 {
+			i += n
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if rw := runewidth.RuneWidth(r); w+rw > width {
+			return s[:i] + "\033[0m" + strings.Repeat(" ", width-w)
+		} else {
+			w += rw
+		}
+		i += size
 	}
+	return s + strings.Repeat(" ", width-w)
+}
 
-	var arr []TextContent
-	if err := json.Unmarshal(content, &arr); err == nil {
-		var parts []string
-		for _, item := range arr {
-			if item.Type == "text" && item.Text != "" {
-				parts = append(parts, item.Text)
-			}
+// ansiWidth is the number of terminal cells s occupies, ignoring escape
+// sequences.
+func ansiWidth(s string) int {
+	w := 0
+	for i := 0; i < len(s); {
+		if n := escapeLen(s[i:]); n > 0 {
+			i += n
+			continue
 		}
-		return strings.Join(parts, " ")
+		r, size := utf8.DecodeRuneInString(s[i:])
+		w += runewidth.RuneWidth(r)
+		i += size
 	}
-
-	return ""
+	return w
 }
 
+// ============================================================================
+// Markdown - styled message text in the preview
+// ============================================================================
 
+// markdown styles message text line by line: headings, bullets, **bold**,
+// `inline code` and fenced code blocks, syntax-highlighted by a small lexer.
+// It remembers whether a code block is open, so each message needs its own.
+// A nil *markdown renders the text raw.
+// ponytail: one line at a time - setext headings, tables and multi-line
+// strings or comments in code are left as they are.
+type markdown struct {
+	fence string    // the open code block's fence ("```" or "~~~"), or ""
+	lang  *codeLang // the open code block's language (nil: unknown)
+}
 
+// Markdown and code styles. Match highlights take precedence over them.
+const (
+	mdHeading    = "\033[1;4m" // # Heading: bold, underlined
+	mdSubheading = "\033[1m"   // ## and deeper: bold
+	mdBold       = "\033[1m"
+	mdCode       = "\033[36m" // `inline code`: cyan
+	mdFence      = "\033[90m" // ``` lines: gray
+	mdBullet     = "\033[33m" // •: yellow
+	codeKeyword  = "\033[35m" // magenta
+	codeString   = "\033[32m" // green
+	codeComment  = "\033[90m" // gray
+	codeNumber   = "\033[33m" // yellow
+)
 
-
-func parseConversationFile(path string, cutoff time.Time, maxSize int64) (*Conversation, error) {
-	info, err := os.Stat(path)
-	if err != nil {
-		return nil, err
+// line renders one line of message text, its matches already marked.
+func (md *markdown) line(tr []rune, marked []bool) string {
+	if marked == nil {
+		marked = make([]bool, len(tr))
 	}
-
-	if strings.HasPrefix(info.Name(), "agent-") {
-		return nil, nil
+	if md == nil {
+		return renderMarked(tr, marked)
 	}
-
-	// Skip files larger than maxSize (0 means no limit)
-	if maxSize > 0 && info.Size() > maxSize {
-		return nil, nil
+	lead := 0
+	for lead < len(tr) && (tr[lead] == ' ' || tr[lead] == '\t') {
+		lead++
 	}
+	body := string(tr[lead:])
+	var l styledLine
 
-	// Skip files not modified since cutoff (file mtime check)
-	if !cutoff.IsZero() && info.ModTime().Before(cutoff) {
-		return nil, nil
+	if md.fence != "" {
+		if strings.HasPrefix(body, md.fence) {
+			md.fence, md.lang = "", nil
+			l.add(tr, marked, mdFence)
+		} else {
+			l.addStyled(tr, marked, md.lang.styles(tr))
+		}
+		return l.render()
 	}
 
-	sessionID := strings.TrimSuffix(info.Name(), ".jsonl")
-	conv := &Conversation{
-		SessionID: sessionID,
-		FilePath:  path,
-		Size:      info.Size(),
+	switch {
+	case strings.HasPrefix(body, "```") || strings.HasPrefix(body, "~~~"):
+		md.fence = body[:3]
+		if info := strings.Fields(body[3:]); len(info) > 0 {
+			md.lang = codeLangs[strings.ToLower(info[0])]
+		}
+		l.add(tr, marked, mdFence)
+	case headingLevel(body) > 0:
+		level := headingLevel(body)
+		style := mdSubheading
+		if level == 1 {
+			style = mdHeading
+		}
+		text := lead + level + 1 // drop the #s and the space after them
+		l.add(tr[:lead], marked[:lead], "")
+		l.inline(tr[text:], marked[text:], style)
+	case strings.HasPrefix(body, "- ") || strings.HasPrefix(body, "* ") || strings.HasPrefix(body, "+ "):
+		l.add(tr[:lead], marked[:lead], "")
+		l.add([]rune{'•'}, marked[lead:lead+1], mdBullet)
+		l.inline(tr[lead+1:], marked[lead+1:], "")
+	default:
+		l.inline(tr, marked, "")
 	}
+	return l.render()
+}
 
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, err
+// headingLevel is the level of an ATX heading ("## Title" is 2), or 0.
+func headingLevel(s string) int {
+	n := 0
+	for n < len(s) && n < 7 && s[n] == '#' {
+		n++
 	}
-	defer file.Close()
+	if n == 0 || n > 6 || n == len(s) || s[n] != ' ' {
+		return 0
+	}
+	return n
+}
 
-	scanner := bufio.NewScanner(file)
-	// A single JSONL line holds a whole turn - a big tool result or a base64
-	// image can be tens of MB. ponytail: 64MB ceiling; if a line ever exceeds
-	// it the scanner.Err() check below skips the file rather than silently
-	// truncating the parse.
-	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+// styledLine accumulates runes with their match marks and styles, dropping
+// markup (backticks, asterisks, #s) as it goes.
+type styledLine struct {
+	runes  []rune
+	marked []bool
+	styles []string
+}
 
-	for scanner.Scan() {
-		lineBytes := scanner.Bytes()
+func (l *styledLine) add(tr []rune, marked []bool, style string) {
+	l.runes = append(l.runes, tr...)
+	l.marked = append(l.marked, marked...)
+	for range tr {
+		l.styles = append(l.styles, style)
+	}
+}
 
-		var raw RawMessage
-		if err := json.Unmarshal(lineBytes, &raw); err != nil {
+func (l *styledLine) addStyled(tr []rune, marked []bool, styles []string) {
+	l.runes = append(l.runes, tr...)
+	l.marked = append(l.marked, marked...)
+	l.styles = append(l.styles, styles...)
+}
+
+// inline adds text in style, rendering `code` spans and **bold** within it.
+// Unclosed markers are kept as typed.
+func (l *styledLine) inline(tr []rune, marked []bool, style string) {
+	start := 0 // of the pending plain run
+	for i := 0; i < len(tr); {
+		delim := []rune(nil)
+		inner := style
+		switch {
+		case tr[i] == '`':
+			delim, inner = tr[i:i+1], mdCode
+		case tr[i] == '*' && i+1 < len(tr) && tr[i+1] == '*':
+			delim, inner = tr[i:i+2], style+mdBold
+		default:
+			i++
 			continue
 		}
-
-		if raw.Type == "custom-title" {
-			conv.Title = raw.CustomTitle // user-set name wins over ai-title
-			conv.IsCustomTitle = raw.CustomTitle != ""
-		} else if raw.Type == "ai-title" {
-			if conv.Title == "" {
-				conv.Title = raw.AiTitle
-			}
-		} else if raw.Type == "user" {
-			if conv.Cwd == "" {
-				conv.Cwd = raw.Cwd
-			}
-			text := extractText(raw.Message.Content)
-			if strings.TrimSpace(text) != "" {
-				if conv.FirstTimestamp == "" {
-					conv.FirstTimestamp = raw.Timestamp
-				}
-				conv.Messages = append(conv.Messages, Message{
-					Role: "user",
-					Text: text,
-					Ts:   raw.Timestamp,
-				})
-			}
-		} else if raw.Type == "assistant" {
-			text := extractText(raw.Message.Content)
-			if strings.TrimSpace(text) != "" {
-				conv.Messages = append(conv.Messages, Message{
-					Role: "assistant",
-					Text: text,
-					Ts:   raw.Timestamp,
-				})
-			}
+		open := i + len(delim)
+		end := indexRunes(tr[open:], delim)
+		if end <= 0 { // unclosed or empty
+			i = open
+			continue
 		}
+		end += open
+		l.add(tr[start:i], marked[start:i], style)
+		l.add(tr[open:end], marked[open:end], inner)
+		i = end + len(delim)
+		start = i
 	}
+	l.add(tr[start:], marked[start:], style)
+}
 
-	// A scan error (e.g. a line over the buffer cap) leaves the parse partial.
-	// Surface it instead of trusting a silently-truncated conversation.
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("reading %s: %w", path, err)
+// indexRunes is the index of the first sub in tr, or -1.
+func indexRunes(tr, sub []rune) int {
+	for i := 0; i+len(sub) <= len(tr); i++ {
+		if slices.Equal(tr[i:i+len(sub)], sub) {
+			return i
+		}
 	}
+	return -1
+}
 
-	if len(conv.Messages) == 0 {
-		return nil, nil
+// render writes the line, with matches highlighted over its styles.
+func (l *styledLine) render() string {
+	var b strings.Builder
+	cur := ""
+	for i, r := range l.runes {
+		style := l.styles[i]
+		if l.marked[i] {
+			style = highlightStart
+		}
+		if style != cur {
+			if cur != "" {
+				b.WriteString("\033[0m")
+			}
+			b.WriteString(style)
+			cur = style
+		}
+		b.WriteRune(r)
 	}
-
-	conv.LastTimestamp = conv.Messages[len(conv.Messages)-1].Ts
-
-	if conv.Cwd == "" {
-		conv.Cwd = "unknown"
+	if cur != "" {
+		b.WriteString("\033[0m")
 	}
+	return b.String()
+}
 
-	return conv, nil
+// codeLang is what the code block lexer knows about a language.
+type codeLang struct {
+	keywords map[string]bool
+	comments [][]rune // line comment openers
+	quotes   string   // string delimiters
 }
 
-func getConversations(cutoff time.Time, maxSize int64, excludeDirs []string) ([]Conversation, error) {
-	projectsDir := getProjectsDir()
+func newCodeLang(keywords, comments, quotes string) *codeLang {
+	l := &codeLang{keywords: make(map[string]bool), quotes: quotes}
+	for _, k := range strings.Fields(keywords) {
+		l.keywords[k] = true
+	}
+	for _, c := range strings.Fields(comments) {
+		l.comments = append(l.comments, []rune(c))
+	}
+	return l
+}
+
+var (
+	langGo     = newCodeLang("break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var nil true false", "//", "\"'`")
+	langPython = newCodeLang("and as assert async await break class continue def del elif else except finally for from global if import in is lambda nonlocal not or pass raise return try while with yield None True False self", "#", "\"'")
+	langJS     = newCodeLang("async await break case catch class const continue debugger default delete do else export extends finally for from function if import in instanceof interface let new of return super switch this throw try type typeof var void while yield null undefined true false", "//", "\"'`")
+	langRust   = newCodeLang("as async await break const continue crate else enum extern fn for if impl in let loop match mod move mut pub ref return self Self static struct super trait type unsafe use where while true false", "//", "\"")
+	langShell  = newCodeLang("if then else elif fi case esac for while until do done in function return export local echo cd set unset", "#", "\"'")
+	langC      = newCodeLang("auto break case catch char class const continue default delete do double else enum extern final float for if import int long new null private protected public return short signed sizeof static struct switch this throw try typedef union unsigned virtual void while true false", "//", "\"'")
+	langSQL    = newCodeLang(sqlKeywords+" "+strings.ToUpper(sqlKeywords), "--", "'\"")
+	langData   = newCodeLang("true false null yes no", "#", "\"'") // JSON, YAML, TOML
+)
+
+// sqlKeywords are matched in lower and upper case.
+const sqlKeywords = "select from where and or not insert into values update set delete create table index drop alter join left right inner outer on group by order having limit as null is in like distinct union"
+
+// codeLangs maps a fence's info string to its language.
+var codeLangs = map[string]*codeLang{
+	"go": langGo, "golang": langGo,
+	"python": langPython, "py": langPython,
+	"javascript": langJS, "js": langJS, "jsx": langJS, "typescript": langJS, "ts": langJS, "tsx": langJS,
+	"rust": langRust, "rs": langRust,
+	"sh": langShell, "bash": langShell, "shell": langShell, "zsh": langShell, "console": langShell,
+	"c": langC, "cpp": langC, "c++": langC, "java": langC, "cs": langC, "csharp": langC,
+	"sql":  langSQL,
+	"json": langData, "yaml": langData, "yml": langData, "toml": langData,
+}
+
+// styles lexes one line of code: keywords, strings, numbers and comments.
+// A nil language leaves the line plain.
+func (lang *codeLang) styles(tr []rune) []string {
+	styles := make([]string, len(tr))
+	if lang == nil {
+		return styles
+	}
+	fill := func(from, to int, style string) {
+		for k := from; k < to; k++ {
+			styles[k] = style
+		}
+	}
+	for i := 0; i < len(tr); {
+		r := tr[i]
+		if slices.ContainsFunc(lang.comments, func(c []rune) bool { return len(tr)-i >= len(c) && slices.Equal(tr[i:i+len(c)], c) }) {
+			fill(i, len(tr), codeComment)
+			break
+		}
+		j := i + 1
+		switch {
+		case strings.ContainsRune(lang.quotes, r):
+			for j < len(tr) && tr[j] != r {
+				if tr[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			j = min(j+1, len(tr))
+			fill(i, j, codeString)
+		case isWordRune(r):
+			for j < len(tr) && isWordRune(tr[j]) {
+				j++
+			}
+			if unicode.IsDigit(r) {
+				fill(i, j, codeNumber)
+			} else if lang.keywords[string(tr[i:j])] {
+				fill(i, j, codeKeyword)
+			}
+		}
+		i = j
+	}
+	return styles
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// ============================================================================
+// Facet sidebar - browse by project, branch, model and month
+// ============================================================================
+
+// facetKind is a dimension the sidebar (Alt+G) groups conversations by.
+type facetKind int
+
+const (
+	facetProject facetKind = iota
+	facetBranch
+	facetModel
+	facetMonth
+	numFacets
+)
+
+var facetNames = [numFacets]string{"PROJECT", "BRANCH", "MODEL", "MONTH"}
+
+// sidebarWidth is the facet sidebar's width, not counting the "│" after it.
+const sidebarWidth = 30
+
+// facets is the sidebar's state. Values chosen within a facet are alternatives
+// (OR); choices in different facets must all hold (AND), on top of the query.
+type facets struct {
+	open    bool
+	focused bool // keys go to the sidebar rather than the list
+	cursor  int  // index into rows()
+	chosen  [numFacets]map[string]bool
+	counts  [numFacets][]facetCount // over the query's matches, rebuilt by updateFilter
+}
+
+// facetCount is one sidebar entry: a value and how many conversations have it.
+type facetCount struct {
+	value string
+	n     int
+}
+
+// facetValues is what conv contributes to a facet: its project (repository,
+// worktrees grouped, or else Cwd) and month of last activity, and every branch
+// and model it used.
+func facetValues(kind facetKind, conv Conversation) []string {
+	switch kind {
+	case facetProject:
+		return []string{conv.project()}
+	case facetBranch:
+		return conv.Branches
+	case facetModel:
+		return conv.Models
+	case facetMonth:
+		if ts := formatTimestamp(conv.LastTimestamp); len(ts) >= 7 {
+			return []string{ts[:7]}
+		}
+	}
+	return nil
+}
+
+// active reports whether any facet value is chosen.
+func (f *facets) active() bool {
+	for _, chosen := range f.chosen {
+		if len(chosen) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// allows reports whether conv passes the chosen values of every facet but
+// skip (-1: none skipped).
+func (f *facets) allows(conv Conversation, skip facetKind) bool {
+	for kind, chosen := range f.chosen {
+		if len(chosen) == 0 || facetKind(kind) == skip {
+			continue
+		}
+		if !slices.ContainsFunc(facetValues(facetKind(kind), conv), func(v string) bool { return chosen[v] }) {
+			return false
+		}
+	}
+	return true
+}
+
+// filter counts the facet values among the query's matches and returns the
+// matches that pass the chosen values. A facet's counts ignore its own
+// choices, so picking one project still shows how many the others have.
+func (f *facets) filter(matches []listItem) []listItem {
+	for kind := range numFacets {
+		n := make(map[string]int)
+		for _, item := range matches {
+			if f.allows(item.conv, kind) {
+				for _, v := range facetValues(kind, item.conv) {
+					n[v]++
+				}
+			}
+		}
+		for v := range f.chosen[kind] {
+			if _, ok := n[v]; !ok {
+				n[v] = 0 // keep a choice listed so it can be undone
+			}
+		}
+		counts := make([]facetCount, 0, len(n))
+		for v, c := range n {
+			counts = append(counts, facetCount{v, c})
+		}
+		slices.SortFunc(counts, func(a, b facetCount) int {
+			if kind == facetMonth { // newest first
+				return strings.Compare(b.value, a.value)
+			}
+			return cmp.Or(cmp.Compare(b.n, a.n), strings.Compare(a.value, b.value))
+		})
+		f.counts[kind] = counts
+	}
+	f.cursor = min(f.cursor, max(0, len(f.rows())-1))
+
+	if !f.active() {
+		return matches
+	}
+	out := make([]listItem, 0, len(matches))
+	for _, item := range matches {
+		if f.allows(item.conv, -1) {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// facetRow is a selectable sidebar line.
+type facetRow struct {
+	kind facetKind
+	facetCount
+}
+
+// rows lists the sidebar's values in display order, for the cursor.
+func (f *facets) rows() []facetRow {
+	var rows []facetRow
+	for kind, counts := range f.counts {
+		for _, c := range counts {
+			rows = append(rows, facetRow{facetKind(kind), c})
+		}
+	}
+	return rows
+}
+
+// toggle chooses or unchooses the value under the cursor.
+func (f *facets) toggle() {
+	rows := f.rows()
+	if f.cursor >= len(rows) {
+		return
+	}
+	r := rows[f.cursor]
+	if f.chosen[r.kind] == nil {
+		f.chosen[r.kind] = make(map[string]bool)
+	}
+	if f.chosen[r.kind][r.value] {
+		delete(f.chosen[r.kind], r.value)
+	} else {
+		f.chosen[r.kind][r.value] = true
+	}
+}
+
+// updateSidebar handles keys while the sidebar has focus.
+func (m model) updateSidebar(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	f := &m.facets
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "esc":
+		f.focused = false // back to the list, choices kept
+	case "alt+g":
+		m.facets = facets{} // close, dropping the choices
+		m.relayout()
+		m.resort()
+		return m, tea.ClearScreen
+	case "up", "ctrl+p", "k":
+		f.cursor = max(0, f.cursor-1)
+	case "down", "ctrl+n", "j":
+		f.cursor = min(f.cursor+1, max(0, len(f.rows())-1))
+	case "enter", " ":
+		f.toggle()
+		m.resort()
+	case "backspace":
+		f.chosen = [numFacets]map[string]bool{}
+		m.resort()
+	}
+	return m, nil
+}
+
+// renderSidebar draws the sidebar as height lines, scrolled to keep the
+// cursor in view: a title and rule in line with the list's header, then
+// each facet's values with their counts.
+func (m model) renderSidebar(height int) []string {
+	f := &m.facets
+	title := "FACETS  \033[90mAlt+G"
+	if f.focused {
+		title = "FACETS  \033[90m↑/↓ Enter Bksp Esc"
+	}
+	lines := []string{"  \033[1;36m" + title + "\033[0m", strings.Repeat("─", sidebarWidth)}
+
+	var body []string
+	cursorLine := 0
+	row := 0
+	for k, counts := range f.counts {
+		kind := facetKind(k)
+		if len(counts) == 0 {
+			continue
+		}
+		if len(body) > 0 {
+			body = append(body, "")
+		}
+		body = append(body, " \033[1;33m"+facetNames[kind]+"\033[0m")
+		for _, c := range counts {
+			mark := "[ ]"
+			if f.chosen[kind][c.value] {
+				mark = "[x]"
+			}
+			value := c.value
+			if kind == facetProject {
+				value = filepath.Base(value)
+			}
+			count := strconv.Itoa(c.n)
+			line := fmt.Sprintf(" %s %s %s", mark, padRight(truncate(value, sidebarWidth-7-len(count)), sidebarWidth-7-len(count)), count)
+			if f.focused && row == f.cursor {
+				line = "\033[7m" + line + "\033[0m"
+				cursorLine = len(body)
+			} else if f.chosen[kind][c.value] {
+				line = "\033[1m" + line + "\033[0m"
+			}
+			body = append(body, line)
+			row++
+		}
+	}
+	if len(body) == 0 {
+		body = append(body, "  \033[90mNo conversations\033[0m")
+	}
+
+	visible := max(1, height-len(lines))
+	scroll := min(max(0, cursorLine-visible+1), max(0, len(body)-visible))
+	lines = append(lines, body[scroll:min(len(body), scroll+visible)]...)
+	for len(lines) < height {
+		lines = append(lines, "")
+	}
+	return lines[:height]
+}
+
+// sidebarCols is the width the sidebar takes from the list when open.
+func (m model) sidebarCols() int {
+	if !m.facets.open {
+		return 0
+	}
+	return sidebarWidth + 1
+}
+
+// ============================================================================
+// Transcript viewer - one conversation, every message, full screen
+// ============================================================================
+
+// transcript is the full-screen reader (Ctrl+O) for one conversation. It has a
+// search of its own, so the list's query and cursor are untouched on return.
+type transcript struct {
+	conv      Conversation
+	search    textinput.Model
+	searching bool // search input has focus
+	opts      searchOpts
+	display   previewOpts
+	query     searchQuery
+	lines     []string // every message, rendered for query and display
+	scroll    int
+}
+
+// openTranscript opens the viewer on the selected conversation, searching for
+// the list's query to start with.
+func (m *model) openTranscript() {
+	if len(m.filtered) == 0 {
+		return
+	}
+	ti := textinput.New()
+	ti.Prompt = "/"
+	ti.Placeholder = "search this conversation"
+	ti.Width = 40
+	ti.SetValue(m.textInput.Value())
+	v := &transcript{conv: m.filtered[m.cursor].conv, search: ti, opts: m.opts, display: m.display}
+	v.display.width = m.width // full screen, whatever the preview pane's width
+	v.render()
+	if scroll, ok := matchScroll(matchLines(v.lines), -matchContext-1, 1); ok {
+		v.scroll = scroll
+	}
+	m.viewer = v
+}
+
+// render rebuilds the transcript lines for the current search and display.
+func (v *transcript) render() {
+	v.query = parseQueryOpts(v.search.Value(), v.opts)
+	q := v.query
+	if q.err != nil {
+		q = searchQuery{}
+	}
+	v.lines = v.lines[:0]
+	for _, msg := range v.conv.Messages {
+		matched := q.hasText() && q.searches(msg) && q.matchMessage(msg)
+		v.lines = append(v.lines, messageLines(msg, q, v.display, matched, true)...)
+		v.lines = append(v.lines, "")
+	}
+}
+
+// transcriptHeight is the number of transcript lines on screen: all but the title,
+// two rules and the status line.
+func (m model) transcriptHeight() int {
+	return max(1, m.height-4)
+}
+
+// maxScroll keeps the last page of the transcript filled.
+func (v *transcript) maxScroll(height int) int {
+	return max(0, len(v.lines)-height)
+}
+
+// updateTranscript handles keys while the viewer is open.
+func (m model) updateTranscript(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	v := m.viewer
+	height := m.transcriptHeight()
+
+	if v.searching {
+		switch msg.String() {
+		case "enter":
+			v.searching = false
+			v.search.Blur()
+			return m, nil
+		case "esc":
+			v.searching = false
+			v.search.Blur()
+			v.search.SetValue("")
+			v.render()
+			v.scroll = min(v.scroll, v.maxScroll(height))
+			return m, nil
+		}
+		var cmd tea.Cmd
+		prev := v.search.Value()
+		v.search, cmd = v.search.Update(msg)
+		if v.search.Value() != prev {
+			// Incremental: stay put if a match is on screen, else move to the
+			// next one below.
+			v.render()
+			matches := matchLines(v.lines)
+			onScreen := slices.ContainsFunc(matches, func(line int) bool {
+				return line >= v.scroll && line < v.scroll+height
+			})
+			if scroll, ok := matchScroll(matches, v.scroll-matchContext-1, 1); ok && !onScreen {
+				v.scroll = scroll
+			}
+			v.scroll = min(v.scroll, v.maxScroll(height))
+		}
+		return m, cmd
+	}
+
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "esc", "ctrl+o", "q":
+		m.viewer = nil
+		return m, nil
+	case "/":
+		v.searching = true
+		return m, v.search.Focus()
+	case "n", "alt+n", "N", "alt+p":
+		dir := 1
+		if msg.String() == "N" || msg.String() == "alt+p" {
+			dir = -1
+		}
+		if scroll, ok := matchScroll(matchLines(v.lines), v.scroll, dir); ok {
+			v.scroll = scroll
+		}
+	case "alt+e":
+		v.display.expandThinking = !v.display.expandThinking
+		v.render()
+	case "alt+m":
+		v.display.raw = !v.display.raw
+		v.render()
+	case "up", "ctrl+p", "k":
+		v.scroll--
+	case "down", "ctrl+n", "j":
+		v.scroll++
+	case "pgup", "ctrl+k", "b":
+		v.scroll -= height
+	case "pgdown", "ctrl+j", " ":
+		v.scroll += height
+	case "home", "g":
+		v.scroll = 0
+	case "end", "G":
+		v.scroll = v.maxScroll(height)
+	}
+	v.scroll = max(0, min(v.scroll, v.maxScroll(height)))
+	return m, nil
+}
+
+// viewTranscript renders the viewer over the whole screen.
+func (m model) viewTranscript() string {
+	v := m.viewer
+	var b strings.Builder
+
+	help := "Back:Esc Search:/ Match:n/N Scroll:↑/↓ PgUp/PgDn Thinking:Alt+E Raw:Alt+M"
+	helpWidth := runewidth.StringWidth(help)
+	title := truncate(getTopic(v.conv), max(10, m.width-2-helpWidth-2))
+	padding := max(1, m.width-2-runewidth.StringWidth(title)-helpWidth)
+	b.WriteString(fmt.Sprintf("  \033[1;36m%s\033[0m%s\033[90m%s\033[0m\n", title, strings.Repeat(" ", padding), help))
+	b.WriteString(strings.Repeat("─", m.width))
+	b.WriteString("\n")
+
+	height := m.transcriptHeight()
+	scroll := min(v.scroll, v.maxScroll(height)) // the terminal may have grown
+	end := min(scroll+height, len(v.lines))
+	for _, line := range v.lines[scroll:end] {
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	for i := end - scroll; i < height; i++ {
+		b.WriteString("\n")
+	}
+	b.WriteString(strings.Repeat("─", m.width))
+	b.WriteString("\n")
+
+	// Status: the search box, what it matched and where we are.
+	status := fmt.Sprintf("%d messages", v.conv.messageCount())
+	if v.query.err != nil {
+		status = "\033[31m" + v.query.err.Error() + "\033[90m"
+	} else if matches := matchLines(v.lines); len(matches) > 0 {
+		status = fmt.Sprintf("%d matches", len(matches))
+		if k := currentMatch(matches, scroll); k > 0 {
+			status = fmt.Sprintf("match %d/%d", k, len(matches))
+		}
+	} else if v.query.hasText() {
+		status = "no matches"
+	}
+	if len(v.lines) > height {
+		status += fmt.Sprintf("  %d%%", 100*end/len(v.lines))
+	}
+	search := ""
+	if v.searching || v.search.Value() != "" {
+		search = v.search.View()
+	}
+	b.WriteString(fmt.Sprintf("  %s  \033[90m%s\033[0m", search, status))
+	return b.String()
+}
+
+// ============================================================================
+// Here - the list limited to the current project (--here, Alt+H)
+// ============================================================================
+
+// hereScope is where --here limits the list to: the directory ccs was started
+// in and, inside a git repository, the whole repository with its worktrees.
+type hereScope struct {
+	dirs []string // a conversation whose Cwd is one of these, or below one, is in scope
+	name string   // shown in the search line
+}
+
+// newHereScope resolves the scope for dir, with symlinks both as given and
+// resolved, since Claude Code may have recorded either.
+func newHereScope(dir string) hereScope {
+	var sc hereScope
+	add := func(d string) {
+		if d == "" {
+			return
+		}
+		sc.dirs = appendNew(sc.dirs, d)
+		if real, err := filepath.EvalSymlinks(d); err == nil {
+			sc.dirs = appendNew(sc.dirs, real)
+		}
+	}
+	repo := repoRoot(dir)
+	add(dir)
+	add(gitRoot(dir)) // the worktree itself
+	add(repo)
+	sc.name = filepath.Base(cmp.Or(repo, dir))
+	return sc
+}
+
+// gitRoot is the top of the git repository (or worktree) containing dir, or
+// "" outside one.
+func gitRoot(dir string) string {
+	for d := dir; d != ""; d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			return d
+		}
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	return ""
+}
+
+// repoRoots memoises repoRoot: sessions share a handful of directories, and
+// files are parsed concurrently.
+var repoRoots sync.Map // dir -> string
+
+// repoRoot is the main checkout of the git repository containing dir, so a
+// linked worktree resolves to the repository it was added to. It is "" outside
+// a repository.
+// ponytail: that includes a worktree that has since been removed - its
+// sessions then group under their own directory.
+func repoRoot(dir string) string {
+	if v, ok := repoRoots.Load(dir); ok {
+		return v.(string)
+	}
+	root := gitRoot(dir)
+	if root != "" {
+		root = mainCheckout(root)
+	}
+	repoRoots.Store(dir, root)
+	return root
+}
+
+// mainCheckout follows a worktree's .git file ("gitdir: <repo>/.git/worktrees/
+// <name>") to the repository's own .git directory, named by the commondir file
+// there. A submodule's .git file leads to no commondir, and it is a repository
+// of its own.
+func mainCheckout(root string) string {
+	data, err := os.ReadFile(filepath.Join(root, ".git"))
+	if err != nil { // a directory: root is the main checkout
+		return root
+	}
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !ok {
+		return root
+	}
+	gitDir = strings.TrimSpace(gitDir)
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(root, gitDir)
+	}
+	common, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return root
+	}
+	dotGit := strings.TrimSpace(string(common))
+	if !filepath.IsAbs(dotGit) {
+		dotGit = filepath.Join(gitDir, dotGit)
+	}
+	dotGit = filepath.Clean(dotGit)
+	if filepath.Base(dotGit) != ".git" { // a bare repository has no checkout
+		return dotGit
+	}
+	return filepath.Dir(dotGit)
+}
+
+// contains reports whether conv ran in the scope: below one of its directories,
+// or in another worktree of its repository.
+func (sc hereScope) contains(conv Conversation) bool {
+	return slices.ContainsFunc(sc.dirs, func(d string) bool {
+		return underDir(conv.Cwd, d) || conv.repo != "" && underDir(conv.repo, d)
+	})
+}
+
+// underDir reports whether path is dir or inside it.
+func underDir(path, dir string) bool {
+	sep := string(filepath.Separator)
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, sep)+sep)
+}
+
+// inScope reports whether item is listed: always, unless --here is on.
+func (m model) inScope(item listItem) bool {
+	return !m.here || m.scope.contains(item.conv)
+}
+
+// ============================================================================
+// Search query - free text plus field qualifiers (project:, size:>50MB, ...)
+// ============================================================================
+
+// searchQuery is a parsed search box value: clauses that must all match, each
+// a set of alternatives joined by OR. Terms are free text (a word or "quoted
+// phrase", optionally -negated) or metadata qualifiers. With role:, free text
+// only matches that role's messages.
+type searchQuery struct {
+	raw     string
+	opts    searchOpts
+	clauses [][]queryTerm // ANDed; the terms within a clause are ORed
+	role    string        // role: qualifier ("" = any)
+	err     error         // first invalid qualifier; the query must not be applied
+}
+
+// searchOpts are the search modes that change how free text matches.
+type searchOpts struct {
+	fuzzy    bool     // words match as in-order characters with gaps, results ranked
+	regex    bool     // words and phrases are regular expressions
+	tools    bool     // tool calls and results are searched (and previewed) too
+	thinking bool     // thinking blocks are searched too
+	caseMode caseMode // how letter case is matched
+}
+
+// caseMode is how free text matches letter case.
+type caseMode int
+
+const (
+	caseSmart     caseMode = iota // a term with an uppercase letter matches case
+	caseSensitive                 // every term matches case
+	caseIgnore                    // no term matches case
+)
+
+// caseModeNames are the search line labels (smart, the default, isn't shown).
+var caseModeNames = map[caseMode]string{caseSmart: "smart", caseSensitive: "case", caseIgnore: "nocase"}
+
+// caseModeFlags are the --case values.
+var caseModeFlags = map[string]caseMode{"smart": caseSmart, "sensitive": caseSensitive, "ignore": caseIgnore}
+
+// queryTerm is one word, phrase or qualifier of a query.
+type queryTerm struct {
+	token  string                       // as typed, to compare queries
+	text   string                       // free text to find, folded unless cased ("" for a qualifier)
+	filter func(conv Conversation) bool // metadata qualifier; nil for free text
+	neg    bool                         // -term: must NOT match
+	fuzzy  bool                         // fuzzy mode word (phrases and exclusions stay exact)
+	re     *regexp.Regexp               // /regex/ or regex mode term
+	cased  bool                         // matches letter case (see caseMode)
+}
+
+// qualifiers maps each qualifier key to a parser for its value. Any other
+// word:value is taken for a mistyped qualifier and reported (see parseTerm),
+// except a URL; quoted, it searches as typed.
+var qualifiers = map[string]func(q *searchQuery, value string) (func(Conversation) bool, error){
+	"project": func(_ *searchQuery, v string) (func(Conversation) bool, error) {
+		v = fold(v)
+		return func(c Conversation) bool {
+			return strings.Contains(fold(c.Cwd), v) || strings.Contains(fold(c.repo), v)
+		}, nil
+	},
+	"branch": func(_ *searchQuery, v string) (func(Conversation) bool, error) {
+		v = fold(v)
+		return func(c Conversation) bool {
+			return slices.ContainsFunc(c.Branches, func(b string) bool { return strings.Contains(fold(b), v) })
+		}, nil
+	},
+	"title": func(_ *searchQuery, v string) (func(Conversation) bool, error) {
+		v = fold(v)
+		return func(c Conversation) bool { return strings.Contains(fold(getTopic(c)), v) }, nil
+	},
+	"session": func(_ *searchQuery, v string) (func(Conversation) bool, error) {
+		v = fold(v)
+		return func(c Conversation) bool { return strings.HasPrefix(fold(c.SessionID), v) }, nil
+	},
+	"role": func(q *searchQuery, v string) (func(Conversation) bool, error) {
+		v = strings.ToLower(v)
+		if v != "user" && v != "assistant" {
+			return nil, fmt.Errorf("role:%s - expected role:user or role:assistant", v)
+		}
+		q.role = v
+		return nil, nil // scopes the text match rather than filtering
+	},
+	"after": func(_ *searchQuery, v string) (func(Conversation) bool, error) {
+		t, err := parseQueryDate(v)
+		if err != nil {
+			return nil, fmt.Errorf("after:%s - %v", v, err)
+		}
+		return func(c Conversation) bool {
+			ts, err := time.Parse(time.RFC3339, c.LastTimestamp)
+			return err == nil && !ts.Before(t)
+		}, nil
+	},
+	"before": func(_ *searchQuery, v string) (func(Conversation) bool, error) {
+		t, err := parseQueryDate(v)
+		if err != nil {
+			return nil, fmt.Errorf("before:%s - %v", v, err)
+		}
+		return func(c Conversation) bool {
+			ts, err := time.Parse(time.RFC3339, c.FirstTimestamp)
+			return err == nil && ts.Before(t)
+		}, nil
+	},
+	"size": func(_ *searchQuery, v string) (func(Conversation) bool, error) {
+		compare, n, err := parseComparison(v, parseByteSize)
+		if err != nil {
+			return nil, fmt.Errorf("size:%s - %v", v, err)
+		}
+		return func(c Conversation) bool { return compare(c.Size, n) }, nil
+	},
+	"msgs": func(_ *searchQuery, v string) (func(Conversation) bool, error) {
+		compare, n, err := parseComparison(v, parseCount)
+		if err != nil {
+			return nil, fmt.Errorf("msgs:%s - %v", v, err)
+		}
+		return func(c Conversation) bool { return compare(int64(c.messageCount()), n) }, nil
+	},
+}
+
+// isQualifierKey reports whether key looks like a qualifier's: letters only,
+// so "10:30" or "C++:" stay text.
+func isQualifierKey(key string) bool {
+	return key != "" && !strings.ContainsFunc(key, func(r rune) bool { return !unicode.IsLetter(r) })
+}
+
+// unknownQualifier is the error for key:value with no such qualifier,
+// suggesting the one key was most likely a typo of.
+func unknownQualifier(key, value string) error {
+	hint := "quote it to search for the text"
+	best, bestDist := "", 3 // further off isn't a typo
+	for name := range qualifiers {
+		if d := editDistance(strings.ToLower(key), name); d < bestDist || d == bestDist && name < best {
+			best, bestDist = name, d
+		}
+	}
+	if best != "" {
+		hint = "did you mean " + best + ":? Or " + hint
+	}
+	return fmt.Errorf("%s:%s - unknown qualifier (%s)", key, value, hint)
+}
+
+// editDistance is the Levenshtein distance between a and b, in runes.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			sub := prev[j-1]
+			if ra[i-1] != rb[j-1] {
+				sub++
+			}
+			cur[j] = min(sub, prev[j]+1, cur[j-1]+1)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// parseQuery parses raw into clauses. Whitespace separates ANDed terms, OR
+// (uppercase) joins its neighbours into alternatives, "..." quotes a phrase,
+// /.../ is a regular expression and a leading - negates a term. A qualifier
+// with no value yet (mid-typing "project:") is ignored rather than reported.
+func parseQuery(raw string) searchQuery {
+	return parseQueryOpts(raw, searchOpts{})
+}
+
+// parseQueryOpts is parseQuery under the given search modes.
+func parseQueryOpts(raw string, opts searchOpts) searchQuery {
+	q := searchQuery{raw: raw, opts: opts}
+	orNext := false
+	for _, tok := range tokenizeQuery(raw) {
+		if tok == "OR" {
+			orNext = len(q.clauses) > 0
+			continue
+		}
+		term, ok := q.parseTerm(tok)
+		if !ok {
+			continue
+		}
+		if n := len(q.clauses); orNext && n > 0 {
+			q.clauses[n-1] = append(q.clauses[n-1], term)
+		} else {
+			q.clauses = append(q.clauses, []queryTerm{term})
+		}
+		orNext = false
+	}
+	return q
+}
+
+// tokenizeQuery splits on whitespace outside double quotes, keeping the quotes.
+// A token starting with / runs to the next / that ends a word, so a /regex/
+// may contain spaces (and quotes).
+func tokenizeQuery(raw string) []string {
+	var toks []string
+	var cur strings.Builder
+	quoted := false
+	for i := 0; i < len(raw); {
+		r, size := utf8.DecodeRuneInString(raw[i:])
+		if r == '/' && !quoted && (cur.Len() == 0 || cur.String() == "-") {
+			if end := regexEnd(raw[i+1:]); end >= 0 {
+				cur.WriteString(raw[i : i+1+end+1])
+				i += 1 + end + 1
+				continue
+			}
+		}
+		i += size
+		switch {
+		case r == '"':
+			quoted = !quoted
+			cur.WriteRune(r)
+		case unicode.IsSpace(r) && !quoted:
+			if cur.Len() > 0 {
+				toks = append(toks, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		toks = append(toks, cur.String())
+	}
+	return toks
+}
+
+// regexEnd is the byte index in s of the / closing a /regex/ - one followed
+// by whitespace or the end of s - or -1 if there is none.
+func regexEnd(s string) int {
+	for j := 0; j < len(s); j++ {
+		if s[j] != '/' {
+			continue
+		}
+		if next, _ := utf8.DecodeRuneInString(s[j+1:]); j+1 == len(s) || unicode.IsSpace(next) {
+			return j
+		}
+	}
+	return -1
+}
+
+// parseTerm turns one token into a term. ok is false for tokens that add no
+// term: an incomplete qualifier, role: (which scopes the query instead) or an
+// invalid qualifier (recorded in q.err).
+func (q *searchQuery) parseTerm(tok string) (term queryTerm, ok bool) {
+	term.token = tok
+	body := tok
+	if len(body) > 1 && body[0] == '-' {
+		term.neg = true
+		body = body[1:]
+	}
+	unquote := func(s string) string { return strings.ReplaceAll(s, `"`, "") }
+
+	if !strings.HasPrefix(body, `"`) {
+		if key, value, found := strings.Cut(body, ":"); found {
+			parse := qualifiers[strings.ToLower(key)]
+			if parse == nil && isQualifierKey(key) && value != "" && !strings.HasPrefix(value, "//") {
+				if q.err == nil {
+					q.err = unknownQualifier(key, value)
+				}
+				return term, false
+			}
+			if parse != nil {
+				value = unquote(value)
+				if value == "" {
+					return term, false
+				}
+				filter, err := parse(q, value)
+				if err == nil && filter == nil && term.neg {
+					err = fmt.Errorf("%s - %s: can't be negated", tok, key)
+				}
+				if err != nil {
+					if q.err == nil {
+						q.err = err
+					}
+					return term, false
+				}
+				if filter == nil {
+					return term, false
+				}
+				term.filter = filter
+				return term, true
+			}
+		}
+	}
+	text, regex := unquote(body), q.opts.regex
+	if len(body) > 2 && body[0] == '/' && body[len(body)-1] == '/' {
+		text, regex = body[1:len(body)-1], true
+	}
+	text = norm.NFC.String(text) // parsed text is NFC too (see extractContent)
+	switch q.opts.caseMode {
+	case caseSensitive:
+		term.cased = true
+	case caseSmart:
+		term.cased = hasUpper(text, regex)
+	}
+	if regex {
+		if text == "" {
+			return term, false
+		}
+		flags := "(?i)"
+		if term.cased {
+			flags = ""
+		}
+		re, err := regexp.Compile(flags + text)
+		if err != nil {
+			if q.err == nil {
+				q.err = fmt.Errorf("/%s/ - %s", text, strings.TrimPrefix(err.Error(), "error parsing regexp: "))
+			}
+			return term, false
+		}
+		term.text, term.re = text, re
+		return term, true
+	}
+	term.text = text
+	if !term.cased {
+		term.text = fold(text)
+	}
+	term.fuzzy = q.opts.fuzzy && !term.neg && !strings.Contains(body, `"`)
+	return term, term.text != ""
+}
+
+// hasUpper reports whether s has an uppercase letter, for smart case. In a
+// regex, escapes such as \S and \D don't count.
+func hasUpper(s string, regex bool) bool {
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case regex && r == '\\':
+			escaped = true
+		case unicode.IsUpper(r):
+			return true
+		}
+	}
+	return false
+}
+
+// subject picks what a literal or fuzzy term is matched against: text as is
+// if the term matches case, else its folded form lower.
+func (t queryTerm) subject(text, lower string) string {
+	if t.cased {
+		return text
+	}
+	return lower
+}
+
+// inItem reports whether an exact or regex term occurs in a conversation's
+// searchable text, including the tool traffic and thinking opts asks for.
+func (t queryTerm) inItem(item listItem, opts searchOpts) bool {
+	in := func(text, lower string) bool {
+		if t.re != nil {
+			return t.re.MatchString(text)
+		}
+		return strings.Contains(t.subject(text, lower), t.text)
+	}
+	return in(item.searchText, item.searchLower) ||
+		opts.tools && in(item.toolText, item.toolLower) ||
+		opts.thinking && in(item.thinkText, item.thinkLower)
+}
+
+// inText reports whether an exact or regex term occurs in text.
+func (t queryTerm) inText(text string) bool {
+	if t.re != nil {
+		return t.re.MatchString(text)
+	}
+	if t.cased {
+		return strings.Contains(text, t.text)
+	}
+	return strings.Contains(fold(text), t.text)
+}
+
+// countIn is the number of times an exact or regex term occurs in text, with
+// lower being text folded.
+func (t queryTerm) countIn(text, lower string) int {
+	if t.re != nil {
+		return len(t.re.FindAllStringIndex(text, -1))
+	}
+	return strings.Count(t.subject(text, lower), t.text)
+}
+
+// key identifies the query and its modes, for memoising per-query results.
+func (q searchQuery) key() string {
+	return fmt.Sprintf("%+v\x00%s", q.opts, q.raw)
+}
+
+// positive returns the free-text terms that must (or may, under OR) appear -
+// the ones that count as hits and get highlighted.
+func (q searchQuery) positive() []queryTerm {
+	var out []queryTerm
+	for _, clause := range q.clauses {
+		for _, t := range clause {
+			if t.filter == nil && !t.neg {
+				out = append(out, t)
+			}
+		}
+	}
+	return out
+}
+
+// hasText reports whether the query has free text to match and highlight.
+func (q searchQuery) hasText() bool {
+	return len(q.positive()) > 0
+}
+
+// empty reports whether the query matches everything.
+func (q searchQuery) empty() bool {
+	return len(q.clauses) == 0 && q.role == ""
+}
+
+// searches reports whether msg is searched under the query's modes: dialogue
+// always, tool traffic and thinking only when indexed.
+func (q searchQuery) searches(msg Message) bool {
+	switch msg.Kind {
+	case "":
+		return true
+	case kindThinking:
+		return q.opts.thinking
+	}
+	return q.opts.tools
+}
+
+// matchItem reports whether a conversation satisfies every clause.
+func (q searchQuery) matchItem(item listItem) bool {
+	_, ok := q.matchScore(item)
+	return ok
+}
+
+// matchScore reports whether a conversation satisfies every clause, and ranks
+// it: for each clause, the best fuzzy score among its words, summed. The
+// filter pass keeps the score on the item, so ranking doesn't fuzzy-match
+// everything a second time.
+func (q searchQuery) matchScore(item listItem) (score float64, ok bool) {
+	for _, clause := range q.clauses {
+		matched, best := false, 0
+		for _, t := range clause {
+			if !t.fuzzy || t.neg {
+				if !matched && q.matchTerm(t, item) {
+					matched = true
+				}
+				continue
+			}
+			if sc, found := q.termScore(t, item); found {
+				matched, best = true, max(best, sc)
+			}
+		}
+		if !matched {
+			return 0, false
+		}
+		score += float64(best)
+	}
+	return score, true
+}
+
+// matchTerm reports whether one term holds for a conversation.
+func (q searchQuery) matchTerm(t queryTerm, item listItem) bool {
+	if t.filter != nil {
+		return t.filter(item.conv) != t.neg
+	}
+	if t.fuzzy {
+		_, ok := q.termScore(t, item)
+		return ok
+	}
+	found := false
+	if q.role == "" {
+		found = t.inItem(item, q.opts)
+	} else {
+		for _, msg := range item.conv.Messages {
+			if msg.Role == q.role && q.searches(msg) && t.inText(msg.Text) {
+				found = true
+				break
+			}
+		}
+	}
+	return found != t.neg
+}
+
+// matchMessage reports whether one message is a hit: the right role (if
+// role: is set) and containing any of the positive free-text terms.
+// Negations and qualifiers decide which conversations match, not which of
+// their messages are hits.
+func (q searchQuery) matchMessage(msg Message) bool {
+	if q.role != "" && msg.Role != q.role {
+		return false
+	}
+	lower := fold(msg.Text)
+	for _, t := range q.positive() {
+		switch {
+		case t.re != nil:
+			if t.re.MatchString(msg.Text) {
+				return true
+			}
+		case t.fuzzy:
+			if pos, _ := fuzzyMatch([]rune(t.subject(msg.Text, lower)), []rune(t.text)); pos != nil {
+				return true
+			}
+		case strings.Contains(t.subject(msg.Text, lower), t.text):
+			return true
+		}
+	}
+	return false
+}
+
+// narrows reports whether everything q matches is also matched by prev, so
+// q can filter prev's results instead of every conversation. Only queries made
+// of plain ANDed terms are compared; anything with OR falls back to a rescan,
+// as do fuzzy words (a longer pattern may span further than the shorter one's
+// window allows) and regexes (a longer pattern needn't match less).
+func (q searchQuery) narrows(prev searchQuery) bool {
+	if prev.err != nil || q.role != prev.role || q.opts != prev.opts {
+		return false
+	}
+	for _, pc := range prev.clauses {
+		if len(pc) != 1 || pc[0].fuzzy || pc[0].re != nil {
+			return false
+		}
+		p := pc[0]
+		implied := false
+		for _, qc := range q.clauses {
+			if len(qc) != 1 {
+				continue
+			}
+			t := qc[0]
+			switch {
+			case p.filter != nil || p.neg:
+				implied = t.token == p.token // same qualifier or exclusion
+			default:
+				// Text containing "authz" contains "auth".
+				implied = t.filter == nil && !t.neg && t.re == nil && strings.Contains(t.text, p.text)
+			}
+			if implied {
+				break
+			}
+		}
+		if !implied {
+			return false
+		}
+	}
+	return true
+}
+
+// highlight marks the query's positive free-text matches in text: every
+// occurrence of exact terms and regexes, and the individual matched characters
+// of fuzzy words.
+func (q searchQuery) highlight(text string) string {
+	tr, marked := q.marks(text)
+	if marked == nil {
+		return text
+	}
+	return renderMarked(tr, marked)
+}
+
+// marks finds what highlight marks: text's runes, and which of them are part
+// of a match (nil if the query has no free text).
+func (q searchQuery) marks(text string) ([]rune, []bool) {
+	terms := q.positive()
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	// Match on runes so multibyte text (CJK, emoji) is never sliced mid-rune.
+	// Case-insensitive terms match the folded text, which can differ in length
+	// from the original (ß -> ss, é -> e); from maps each folded rune back.
+	tr := []rune(text)
+	var folded []rune
+	var from []int
+	marked := make([]bool, len(tr))
+	var runeAt []int // byte offset -> rune index, for regex match bounds
+	for _, t := range terms {
+		if t.re != nil {
+			if runeAt == nil {
+				runeAt = make([]int, len(text)+1)
+				n := 0
+				for i := range text {
+					runeAt[i] = n
+					n++
+				}
+				runeAt[len(text)] = n
+			}
+			for _, loc := range t.re.FindAllStringIndex(text, -1) {
+				for j := runeAt[loc[0]]; j < runeAt[loc[1]]; j++ {
+					marked[j] = true
+				}
+			}
+			continue
+		}
+		src, origin := tr, []int(nil) // origin[i] is src[i]'s index in tr (nil: i)
+		if !t.cased {
+			if folded == nil {
+				folded, from = foldRunes(text, true)
+			}
+			src, origin = folded, from
+		}
+		mark := func(i int) {
+			if origin != nil {
+				i = origin[i]
+			}
+			marked[i] = true
+		}
+		qr := []rune(t.text)
+		if t.fuzzy {
+			pos, _ := fuzzyMatch(src, qr)
+			for _, p := range pos {
+				mark(p)
+			}
+			continue
+		}
+		for i := 0; i+len(qr) <= len(src); i++ {
+			if slices.Equal(src[i:i+len(qr)], qr) {
+				for j := i; j < i+len(qr); j++ {
+					mark(j)
+				}
+				i += len(qr) - 1
+			}
+		}
+	}
+
+	return tr, marked
+}
+
+// highlightStart begins a highlighted match: yellow background, black text.
+// Preview lines containing it are the ones match navigation stops at.
+const highlightStart = "\033[43;30m"
+
+// highlightResume looks the same as highlightStart; wrapLine reopens a match
+// cut by a wrap with it, so the match is still counted once.
+const highlightResume = "\033[30;43m"
+
+// renderMarked writes runes with the marked ones highlighted.
+func renderMarked(tr []rune, marked []bool) string {
+	var result strings.Builder
+	for i := 0; i < len(tr); {
+		if !marked[i] {
+			result.WriteRune(tr[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(tr) && marked[j] {
+			j++
+		}
+		result.WriteString(highlightStart)
+		result.WriteString(string(tr[i:j]))
+		result.WriteString("\033[0m")
+		i = j
+	}
+	return result.String()
+}
+
+// ranked reports whether results are ordered by score rather than recency.
+func (q searchQuery) ranked() bool {
+	for _, t := range q.positive() {
+		if t.fuzzy {
+			return true
+		}
+	}
+	return false
+}
+
+// Ranking field weights: a hit in the session name or project counts for more
+// than the same hit buried in a message.
+const (
+	weightTitle   = 3
+	weightProject = 2
+)
+
+// termScore is a fuzzy word's best match in a conversation - the session
+// name, project and messages (only the role's messages under role:). ok is
+// false if it matches nowhere.
+func (q searchQuery) termScore(t queryTerm, item listItem) (score int, ok bool) {
+	pattern := []rune(t.text)
+	// Cheap reject: the characters don't even occur in order across the
+	// conversation's folded text. (Tests build items without
+	// searchLower.)
+	folded := pattern
+	if t.cased {
+		folded = []rune(fold(t.text))
+	}
+	if item.searchLower != "" && !isSubsequence(item.searchLower, folded) &&
+		!(q.opts.tools && isSubsequence(item.toolLower, folded)) &&
+		!(q.opts.thinking && isSubsequence(item.thinkLower, folded)) {
+		return 0, false
+	}
+	var runes []rune // reused across the texts tried
+	try := func(text string, weight int) {
+		if !isSubsequence(text, pattern) {
+			return
+		}
+		runes = runes[:0]
+		for _, r := range text {
+			runes = append(runes, r)
+		}
+		if pos, sc := fuzzyMatch(runes, pattern); pos != nil && sc*weight > score {
+			score, ok = sc*weight, true
+		}
+	}
+	subject := func(text string) string {
+		if t.cased {
+			return text
+		}
+		return fold(text)
+	}
+	if q.role == "" {
+		try(subject(item.conv.Title), weightTitle)
+		try(subject(item.conv.Cwd), weightProject)
+		try(subject(item.conv.SessionID), 1)
+	}
+	for i, msg := range item.conv.Messages {
+		if (q.role == "" || msg.Role == q.role) && q.searches(msg) {
+			if t.cased {
+				try(msg.Text, 1)
+			} else {
+				try(item.messageLower(i), 1)
+			}
+		}
+	}
+	return score, ok
+}
+
+// isSubsequence reports whether pattern's runes appear in order in s.
+func isSubsequence(s string, pattern []rune) bool {
+	i := 0
+	for _, r := range s {
+		if i == len(pattern) {
+			break
+		}
+		if r == pattern[i] {
+			i++
+		}
+	}
+	return i == len(pattern)
+}
+
+// Fuzzy scoring, loosely after fzf: every matched character scores, more so
+// at the start of a word or right after the previous match; gaps cost.
+const (
+	fuzzyScoreMatch       = 16
+	fuzzyBonusBoundary    = 8
+	fuzzyBonusConsecutive = 8
+	fuzzyPenaltyGapStart  = 3
+	fuzzyPenaltyGapExtra  = 1
+	// A match may spread over at most this many times the pattern length, so
+	// "kubctl" finds "kubectl" without every long message matching by chance.
+	fuzzyMaxSpanFactor = 3
+)
+
+// fuzzyMatch finds pattern's runes in order within text (both lowercased),
+// returning the matched positions (nil: no match) and their score: the first
+// match spanning at most fuzzyMaxSpanFactor times the pattern. It walks text
+// once, and at each occurrence of the pattern's last rune scans back - as fzf
+// does to shrink a match - over that bounded window for the shortest match
+// ending there. The cost is linear in text, which matters as this runs over
+// every conversation's full text on each keystroke.
+func fuzzyMatch(text, pattern []rune) ([]int, int) {
+	if len(pattern) == 0 {
+		return nil, 0
+	}
+	last := len(pattern) - 1
+	maxSpan := fuzzyMaxSpanFactor * len(pattern)
+	pos := make([]int, len(pattern))
+	for end := last; end < len(text); end++ {
+		if text[end] != pattern[last] {
+			continue
+		}
+		pi := last
+		for i := end; i >= max(0, end-maxSpan+1) && pi >= 0; i-- {
+			if text[i] == pattern[pi] {
+				pos[pi] = i
+				pi--
+			}
+		}
+		if pi < 0 {
+			return pos, fuzzyScore(text, pos)
+		}
+	}
+	return nil, 0
+}
+
+// fuzzyScore scores matched positions in text.
+func fuzzyScore(text []rune, pos []int) int {
+	score := 0
+	for k, p := range pos {
+		score += fuzzyScoreMatch
+		if p == 0 || !unicode.IsLetter(text[p-1]) && !unicode.IsDigit(text[p-1]) {
+			if k == 0 {
+				score += 2 * fuzzyBonusBoundary
+			} else {
+				score += fuzzyBonusBoundary
+			}
+		}
+		if k > 0 {
+			if gap := p - pos[k-1] - 1; gap == 0 {
+				score += fuzzyBonusConsecutive
+			} else {
+				score -= fuzzyPenaltyGapStart + (gap-1)*fuzzyPenaltyGapExtra
+			}
+		}
+	}
+	return score
+}
+
+// BM25 parameters: k1 caps how much repeating a term keeps adding, b how
+// strongly long conversations are discounted. The usual defaults.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// bm25 scores conversations by relevance to a query's exact and regex terms
+// (fuzzy words are ranked by fuzzy score instead): terms that occur often in a
+// conversation, but in few conversations overall, weigh most.
+type bm25 struct {
+	q      searchQuery
+	terms  []queryTerm
+	idf    []float64
+	avgLen float64
+}
+
+// newBM25 gathers the corpus statistics - document frequencies and average
+// length - over all conversations, not just the current matches.
+// ponytail: rescans every conversation once per term on each keystroke while
+// sorting by relevance; fine for thousands of sessions.
+func newBM25(q searchQuery, corpus []listItem) *bm25 {
+	bm := &bm25{q: q}
+	for _, t := range q.positive() {
+		if !t.fuzzy {
+			bm.terms = append(bm.terms, t)
+		}
+	}
+	total := 0
+	for _, item := range corpus {
+		total += bm.docLen(item)
+	}
+	if len(corpus) > 0 {
+		bm.avgLen = float64(total) / float64(len(corpus))
+	}
+	n := float64(len(corpus))
+	for _, t := range bm.terms {
+		df := 0
+		for _, item := range corpus {
+			if t.inItem(item, q.opts) {
+				df++
+			}
+		}
+		bm.idf = append(bm.idf, math.Log(1+(n-float64(df)+0.5)/(float64(df)+0.5)))
+	}
+	return bm
+}
+
+// score is item's BM25 score, summed over the query's terms.
+func (bm *bm25) score(item listItem) float64 {
+	norm := 1.0
+	if bm.avgLen > 0 {
+		norm = 1 - bm25B + bm25B*float64(bm.docLen(item))/bm.avgLen
+	}
+	total := 0.0
+	for i, t := range bm.terms {
+		tf := float64(bm.termFreq(t, item))
+		total += bm.idf[i] * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+	}
+	return total
+}
+
+// docLen is the length of a conversation's searched text.
+func (bm *bm25) docLen(item listItem) int {
+	n := len(item.searchLower)
+	if bm.q.opts.tools {
+		n += len(item.toolLower)
+	}
+	if bm.q.opts.thinking {
+		n += len(item.thinkLower)
+	}
+	return n
+}
+
+// termFreq counts a term's occurrences in a conversation, those in the session
+// name and project weighted up. Under role: only that role's messages count.
+func (bm *bm25) termFreq(t queryTerm, item listItem) int {
+	conv := item.conv
+	if bm.q.role != "" {
+		n := 0
+		for _, msg := range conv.Messages {
+			if msg.Role == bm.q.role && bm.q.searches(msg) {
+				n += t.countIn(msg.Text, fold(msg.Text))
+			}
+		}
+		return n
+	}
+	// searchLower already counts each field once.
+	n := t.countIn(item.searchText, item.searchLower) +
+		(weightTitle-1)*t.countIn(conv.Title, fold(conv.Title)) +
+		(weightProject-1)*t.countIn(conv.Cwd, fold(conv.Cwd))
+	if bm.q.opts.tools {
+		n += t.countIn(item.toolText, item.toolLower)
+	}
+	if bm.q.opts.thinking {
+		n += t.countIn(item.thinkText, item.thinkLower)
+	}
+	return n
+}
 
-	var files []string
-	err := filepath.Walk(projectsDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil
+// fold normalises s for case- and accent-insensitive matching: compatibility
+// decomposition (NFKD) with the combining marks dropped, then full case
+// folding - "Café", "CAFE" and "cafe" all fold to "cafe", "Straße" to
+// "strasse". Used for both the indexed text and the query.
+func fold(s string) string {
+	ascii := true
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			ascii = false
+			break
 		}
-		if info.IsDir() && info.Name() == "subagents" {
-			return filepath.SkipDir
+	}
+	if ascii {
+		return strings.ToLower(s)
+	}
+	r, _ := foldRunes(s, false)
+	return string(r)
+}
+
+// foldJoin is fold(strings.Join(parts, " ")), along with each part's folded
+// text as a slice of it. Folding works rune by rune, so the two agree.
+func foldJoin(parts []string) (string, []string) {
+	folded := make([]string, len(parts))
+	for i, p := range parts {
+		folded[i] = fold(p)
+	}
+	joined := strings.Join(folded, " ")
+	at := 0
+	for i, f := range folded {
+		folded[i] = joined[at : at+len(f)]
+		at += len(f) + 1
+	}
+	return joined, folded
+}
+
+// foldCasers reuses case folders, which are costly to make and not safe for
+// concurrent use: the loader and the watcher fold text too.
+var foldCasers = sync.Pool{New: func() any { c := cases.Fold(); return &c }}
+
+// foldRunes is fold as runes, plus (if withMap) from: the index of the rune
+// of s each folded rune came from. Folding changes lengths (ß -> ss, ﬁ -> fi,
+// İ -> i), so matches in folded text need from to land on the original runes.
+func foldRunes(s string, withMap bool) (folded []rune, from []int) {
+	var caser *cases.Caser // from foldCasers, on the first rune that needs it
+	defer func() {
+		if caser != nil {
+			foldCasers.Put(caser)
 		}
-		if info.IsDir() {
-			for _, exc := range excludeDirs {
-				if strings.Contains(info.Name(), exc) {
-					return filepath.SkipDir
+	}()
+	folded = make([]rune, 0, len(s))
+	i := 0
+	for _, r := range s {
+		n := len(folded)
+		var buf [utf8.UTFMax]byte
+		switch {
+		case r < utf8.RuneSelf:
+			folded = append(folded, unicode.ToLower(r))
+		case unicode.SimpleFold(r) == r && !unicode.Is(unicode.Mn, r) &&
+			norm.NFKD.Properties(buf[:utf8.EncodeRune(buf[:], r)]).Decomposition() == nil:
+			folded = append(folded, r) // caseless and undecomposable, e.g. CJK
+		default:
+			if caser == nil {
+				caser = foldCasers.Get().(*cases.Caser)
+			}
+			for _, d := range caser.String(norm.NFKD.String(string(r))) {
+				if !unicode.Is(unicode.Mn, d) {
+					folded = append(folded, d)
+				}
+			}
+		}
+		if withMap {
+			for ; n < len(folded); n++ {
+				from = append(from, i)
+			}
+		}
+		i++
+	}
+	return folded, from
+}
+
+// parseQueryDate parses a qualifier date: 2006-01-02, 2006-01 or 2006, in
+// local time.
+func parseQueryDate(v string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", "2006-01", "2006"} {
+		if t, err := time.ParseInLocation(layout, v, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("expected a date like 2026-01-31")
+}
+
+// parseComparison parses ">N", ">=N", "<N", "<=N", "=N" or a bare "N"
+// (meaning >=N), with N read by parseNum.
+func parseComparison(v string, parseNum func(string) (int64, error)) (func(a, b int64) bool, int64, error) {
+	compare := func(a, b int64) bool { return a >= b }
+	for _, o := range []struct {
+		op      string
+		compare func(a, b int64) bool
+	}{
+		{">=", func(a, b int64) bool { return a >= b }},
+		{"<=", func(a, b int64) bool { return a <= b }},
+		{">", func(a, b int64) bool { return a > b }},
+		{"<", func(a, b int64) bool { return a < b }},
+		{"=", func(a, b int64) bool { return a == b }},
+	} {
+		if rest, ok := strings.CutPrefix(v, o.op); ok {
+			v, compare = rest, o.compare
+			break
+		}
+	}
+	n, err := parseNum(v)
+	if err != nil {
+		return nil, 0, err
+	}
+	return compare, n, nil
+}
+
+// parseByteSize parses a size like 512, 20KB, 50MB or 1.5GB (K/M/G also accepted).
+func parseByteSize(s string) (int64, error) {
+	upper := strings.ToUpper(s)
+	mult := int64(1)
+	for _, u := range []struct {
+		suffix string
+		mult   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(upper, u.suffix) {
+			upper = strings.TrimSuffix(upper, u.suffix)
+			mult = u.mult
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(upper, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("expected a size like >50MB")
+	}
+	return int64(f * float64(mult)), nil
+}
+
+// parseCount parses a non-negative whole number.
+func parseCount(s string) (int64, error) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("expected a count like >100")
+	}
+	return n, nil
+}
+
+// ============================================================================
+// Data loading (preserved from original)
+// ============================================================================
+
+// getProjectsDir returns the path to the Claude projects directory
+// Declared as a variable so it can be overridden in tests
+var getProjectsDir = func() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".claude", "projects")
+}
+
+func extractText(content json.RawMessage) string {
+	text, _ := extractContent(content)
+	return text
+}
+
+// extractContent splits message content into its text and its thinking,
+// tool_use and tool_result items, the latter as messages with Kind and Text
+// (and Tool) set. All text is NFC-normalised, so case-sensitive matching
+// (which skips fold) sees é the same whether it was typed precomposed or not.
+func extractContent(content json.RawMessage) (string, []Message) {
+	if len(content) == 0 {
+		return "", nil
+	}
+
+	var str string
+	if err := json.Unmarshal(content, &str); err == nil {
+		return norm.NFC.String(str), nil
+	}
+
+	var arr []TextContent
+	if err := json.Unmarshal(content, &arr); err == nil {
+		var parts []string
+		var extra []Message
+		for _, item := range arr {
+			switch item.Type {
+			case "text":
+				if item.Text != "" {
+					parts = append(parts, item.Text)
 				}
+			case "thinking":
+				if strings.TrimSpace(item.Thinking) != "" {
+					extra = append(extra, Message{Kind: kindThinking, Text: norm.NFC.String(item.Thinking)})
+				}
+			case "tool_use":
+				extra = append(extra, Message{Kind: kindToolUse, Tool: item.Name, Text: capToolText(norm.NFC.String(toolInputText(item.Input)))})
+			case "tool_result":
+				if text := extractText(item.Content); strings.TrimSpace(text) != "" {
+					extra = append(extra, Message{Kind: kindToolResult, Text: capToolText(text)})
+				}
+			}
+		}
+		return norm.NFC.String(strings.Join(parts, " ")), extra
+	}
+
+	return "", nil
+}
+
+// toolTextMax is how much of a tool call's input or result is kept, in bytes:
+// enough for most files written and test logs whole. Beyond it only the head
+// and tail are kept, where commands and errors usually are, so huge dumps
+// don't dominate memory and the cache.
+// ponytail: text in the middle of a tool output over 64KB can't be found.
+const toolTextMax = 64 << 10
+
+// capToolText cuts s to toolTextMax, keeping its head and tail.
+func capToolText(s string) string {
+	if len(s) <= toolTextMax {
+		return s
+	}
+	head, tail := toolTextMax/2, len(s)-toolTextMax/2
+	for head > 0 && !utf8.RuneStart(s[head]) {
+		head--
+	}
+	for tail < len(s) && !utf8.RuneStart(s[tail]) {
+		tail++
+	}
+	return s[:head] + "\n…\n" + s[tail:]
+}
+
+// toolSummaryKeys are the tool_use input fields that best describe a call -
+// Bash's command, Read/Edit/Write's file_path, Grep's pattern and so on.
+var toolSummaryKeys = []string{"command", "file_path", "path", "pattern", "url", "query", "description", "prompt"}
+
+// toolInputText flattens a tool_use input into searchable text: the summary
+// field on the first line, then the remaining values in key order, one per
+// line (non-strings as JSON).
+func toolInputText(input json.RawMessage) string {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(input, &fields); err != nil {
+		return ""
+	}
+	value := func(raw json.RawMessage) string {
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			return s
+		}
+		return string(raw)
+	}
+	var parts []string
+	for _, k := range toolSummaryKeys {
+		if raw, ok := fields[k]; ok {
+			parts = append(parts, value(raw))
+			delete(fields, k)
+			break
+		}
+	}
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if v := value(fields[k]); v != "" {
+			parts = append(parts, v)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func parseConversationFile(path string, cutoff time.Time, maxSize int64) (*Conversation, error) {
+	return (*convCache)(nil).parseConversationFile(path, cutoff, maxSize)
+}
+
+// skipFile reports whether a conversation file is filtered out before parsing:
+// agent sidechains, files over maxSize (0 = no limit) and files not modified
+// since cutoff (zero = no limit).
+func skipFile(info os.FileInfo, cutoff time.Time, maxSize int64) bool {
+	if strings.HasPrefix(info.Name(), "agent-") {
+		return true
+	}
+	if maxSize > 0 && info.Size() > maxSize {
+		return true
+	}
+	return !cutoff.IsZero() && info.ModTime().Before(cutoff)
+}
+
+// parseState is a conversation part-way through parsing: everything read from
+// the first Offset bytes of its file, not yet finalised by conversation().
+// Claude Code only ever appends to session files, so a cached parseState lets a
+// grown file be resumed from Offset instead of reread from byte 0.
+type parseState struct {
+	Conv   Conversation
+	Offset int64  // bytes consumed - always the end of a complete line
+	Tail   []byte // the bytes just before Offset, to detect a rewritten file
+}
+
+// tailLen is how many bytes before Offset are kept to recognise the same file.
+const tailLen = 64
+
+func newParseState(path string) *parseState {
+	return &parseState{Conv: Conversation{
+		SessionID: strings.TrimSuffix(filepath.Base(path), ".jsonl"),
+		FilePath:  path,
+	}}
+}
+
+// clone copies the state, slices included, so resuming never mutates a cached
+// entry in place - the loader and the watcher may resume the same one at once.
+func (st *parseState) clone() *parseState {
+	c := *st
+	c.Conv.Messages = slices.Clone(st.Conv.Messages)
+	c.Conv.Branches = slices.Clone(st.Conv.Branches)
+	c.Conv.Models = slices.Clone(st.Conv.Models)
+	return &c
+}
+
+// parseFile parses the file from st.Offset to info's size, advancing Offset
+// past every complete line. A trailing line that is not yet valid JSON (Claude
+// is mid-write) is left for the next parse. Bytes appended after info was taken
+// are left too, so the state never runs ahead of the size it is cached under.
+func (st *parseState) parseFile(info os.FileInfo) error {
+	path := st.Conv.FilePath
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(io.NewSectionReader(file, st.Offset, max(0, info.Size()-st.Offset)))
+	// A single JSONL line holds a whole turn - a big tool result or a base64
+	// image can be tens of MB. ponytail: 64MB ceiling; if a line ever exceeds
+	// it the scanner.Err() check below skips the file rather than silently
+	// truncating the parse.
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	var advance int // bytes the current token spans, including its newline
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		n, tok, err := bufio.ScanLines(data, atEOF)
+		advance = n
+		return n, tok, err
+	})
+
+	for scanner.Scan() {
+		lineBytes := scanner.Bytes()
+		terminated := advance > len(lineBytes)
+
+		var raw RawMessage
+		if err := json.Unmarshal(lineBytes, &raw); err != nil {
+			if !terminated {
+				break // partial final line: reread it once it is complete
+			}
+			st.Offset += int64(advance)
+			continue
+		}
+		st.apply(raw)
+		st.Offset += int64(advance)
+	}
+
+	// A scan error (e.g. a line over the buffer cap) leaves the parse partial.
+	// Surface it instead of trusting a silently-truncated conversation.
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	st.Conv.Size = info.Size()
+	st.Tail = nil
+	if n := min(st.Offset, tailLen); n > 0 {
+		st.Tail = make([]byte, n)
+		if _, err := file.ReadAt(st.Tail, st.Offset-n); err != nil {
+			st.Tail = nil
+		}
+	}
+	return nil
+}
+
+// resumable reports whether the file at path still starts with everything st
+// has consumed: it hasn't shrunk below Offset (e.g. rewritten by ccs prune) and
+// the bytes before Offset are unchanged.
+func (st *parseState) resumable(info os.FileInfo) bool {
+	if info.Size() < st.Offset || int64(len(st.Tail)) != min(st.Offset, tailLen) {
+		return false
+	}
+	if len(st.Tail) == 0 {
+		return true
+	}
+	f, err := os.Open(st.Conv.FilePath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	buf := make([]byte, len(st.Tail))
+	if _, err := f.ReadAt(buf, st.Offset-int64(len(buf))); err != nil {
+		return false
+	}
+	return bytes.Equal(buf, st.Tail)
+}
+
+// apply folds one parsed JSONL line into the conversation.
+func (st *parseState) apply(raw RawMessage) {
+	conv := &st.Conv
+	if raw.Type == "custom-title" {
+		conv.Title = norm.NFC.String(raw.CustomTitle) // user-set name wins over ai-title
+		conv.IsCustomTitle = raw.CustomTitle != ""
+	} else if raw.Type == "ai-title" {
+		if conv.Title == "" {
+			conv.Title = norm.NFC.String(raw.AiTitle)
+		}
+	} else if raw.Type == "user" {
+		if conv.Cwd == "" {
+			conv.Cwd = raw.Cwd
+		}
+		conv.Branches = appendNew(conv.Branches, raw.GitBranch)
+		text, extra := extractContent(raw.Message.Content)
+		if strings.TrimSpace(text) != "" {
+			if conv.FirstTimestamp == "" {
+				conv.FirstTimestamp = raw.Timestamp
 			}
+			conv.Messages = append(conv.Messages, Message{
+				Role: "user",
+				Text: text,
+				Ts:   raw.Timestamp,
+			})
+		}
+		st.appendExtra("user", raw.Timestamp, extra)
+	} else if raw.Type == "assistant" {
+		conv.Branches = appendNew(conv.Branches, raw.GitBranch)
+		if !strings.HasPrefix(raw.Message.Model, "<") { // e.g. <synthetic> for messages Claude Code made up
+			conv.Models = appendNew(conv.Models, raw.Message.Model)
+		}
+		text, extra := extractContent(raw.Message.Content)
+		if strings.TrimSpace(text) != "" {
+			conv.Messages = append(conv.Messages, Message{
+				Role: "assistant",
+				Text: text,
+				Ts:   raw.Timestamp,
+			})
+		}
+		st.appendExtra("assistant", raw.Timestamp, extra)
+	}
+}
+
+// appendNew appends v unless it is empty or already present.
+func appendNew(list []string, v string) []string {
+	if v == "" || slices.Contains(list, v) {
+		return list
+	}
+	return append(list, v)
+}
+
+// appendExtra adds a line's thinking, tool calls or results after its text.
+// (Claude Code writes each content item on a line of its own, so this keeps
+// the order they happened in.)
+func (st *parseState) appendExtra(role, ts string, extra []Message) {
+	for _, msg := range extra {
+		msg.Role, msg.Ts = role, ts
+		st.Conv.Messages = append(st.Conv.Messages, msg)
+	}
+}
+
+// conversation finalises the parsed state into a Conversation, or nil if the
+// file holds no user/assistant text yet. The state itself is left resumable.
+// Tool traffic doesn't count as activity: LastTimestamp is the last dialogue
+// message's.
+func (st *parseState) conversation() *Conversation {
+	last := -1
+	for i, msg := range st.Conv.Messages {
+		if msg.dialogue() {
+			last = i
+		}
+	}
+	if last < 0 {
+		return nil
+	}
+	conv := st.Conv
+	conv.LastTimestamp = conv.Messages[last].Ts
+	if conv.Cwd == "" {
+		conv.Cwd = "unknown"
+	}
+	return &conv
+}
+
+func getConversations(cutoff time.Time, maxSize int64, excludeDirs []string) ([]Conversation, error) {
+	// Unchanged files come from the on-disk cache; only new or modified ones
+	// are parsed.
+	cache := loadCache()
+	conversations, err := loadConversations(cache, cutoff, maxSize, excludeDirs)
+	if err != nil {
+		return nil, err
+	}
+	// A cache that can't be written only costs the next launch a reparse.
+	_ = cache.save()
+	return conversations, nil
+}
+
+// skipDir reports whether a directory under the projects tree is not searched:
+// subagent sidechains and the user's --exclude list.
+func skipDir(info os.FileInfo, excludeDirs []string) bool {
+	if info.Name() == "subagents" {
+		return true
+	}
+	for _, exc := range excludeDirs {
+		if strings.Contains(info.Name(), exc) {
+			return true
+		}
+	}
+	return false
+}
+
+// isConversationFile reports whether path names a session file (not an agent sidechain).
+func isConversationFile(path string) bool {
+	return strings.HasSuffix(path, ".jsonl") && !strings.HasPrefix(filepath.Base(path), "agent-")
+}
+
+// loadConversations parses every conversation under the projects dir through
+// cache (which may be nil), newest first. Cache entries for files that are no
+// longer present are dropped; saving the cache is left to the caller.
+func loadConversations(cache *convCache, cutoff time.Time, maxSize int64, excludeDirs []string) ([]Conversation, error) {
+	files, err := conversationFiles(excludeDirs)
+	if err != nil {
+		return nil, err
+	}
+
+	var conversations []Conversation
+	for conv := range parseConversations(cache, files, cutoff, maxSize) {
+		if conv != nil {
+			conversations = append(conversations, *conv)
+		}
+	}
+	cache.retain(files)
+
+	sort.Slice(conversations, func(i, j int) bool {
+		return conversations[i].LastTimestamp > conversations[j].LastTimestamp
+	})
+
+	return conversations, nil
+}
+
+// conversationFiles lists the session files under the projects dir, most
+// recently modified first so progressive loading surfaces recent sessions
+// before old multi-GB ones.
+func conversationFiles(excludeDirs []string) ([]string, error) {
+	type fileTime struct {
+		path  string
+		mtime time.Time
+	}
+	var found []fileTime
+	err := filepath.Walk(getProjectsDir(), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() && skipDir(info, excludeDirs) {
+			return filepath.SkipDir
 		}
-		if !info.IsDir() && strings.HasSuffix(path, ".jsonl") && !strings.HasPrefix(info.Name(), "agent-") {
-			files = append(files, path)
+		if !info.IsDir() && isConversationFile(path) {
+			found = append(found, fileTime{path, info.ModTime()})
 		}
 		return nil
 	})
 	if err != nil {
 		return nil, err
 	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].mtime.After(found[j].mtime) })
+	files := make([]string, len(found))
+	for i, f := range found {
+		files[i] = f.path
+	}
+	return files, nil
+}
 
+// parseConversations parses files on a worker pool, taking them in order.
+// It yields exactly one value per file - nil for a file that was skipped, had
+// no text or failed to read - so receivers can count progress, and closes the
+// channel when every file is done.
+func parseConversations(cache *convCache, files []string, cutoff time.Time, maxSize int64) <-chan *Conversation {
 	// Worker pool to limit concurrent file operations
 	const numWorkers = 8
 	jobs := make(chan string, len(files))
@@ -862,10 +4217,11 @@ func getConversations(cutoff time.Time, maxSize int64, excludeDirs []string) ([]
 		go func() {
 			defer wg.Done()
 			for path := range jobs {
-				conv, err := parseConversationFile(path, cutoff, maxSize)
-				if err == nil && conv != nil {
-					results <- conv
+				conv, err := cache.parseConversationFile(path, cutoff, maxSize)
+				if err != nil {
+					conv = nil
 				}
+				results <- conv
 			}
 		}()
 	}
@@ -876,20 +4232,242 @@ func getConversations(cutoff time.Time, maxSize int64, excludeDirs []string) ([]
 	close(jobs)
 
 	go func() {
-		wg.Wait()
-		close(results)
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// loadProgressMsg delivers a batch of conversations parsed by the background
+// loader, with how many of the total files have been processed so far. The
+// items are built by the loader too, so the UI goroutine only merges them.
+type loadProgressMsg struct {
+	items []listItem
+	done  int
+	total int
+	err   error
+}
+
+// loadBatchInterval is how often the background loader hands parsed
+// conversations to the TUI - batching keeps a flood of small files from
+// re-filtering the list once per file.
+const loadBatchInterval = 100 * time.Millisecond
+
+// loadInBackground parses every conversation through cache and sends them to
+// the TUI in batches as they are ready (a tick with nothing new sends nothing),
+// ending with a message where done == total. The cache is saved once everything is loaded.
+func loadInBackground(cache *convCache, cutoff time.Time, maxSize int64, excludeDirs []string, send func(tea.Msg)) {
+	files, err := conversationFiles(excludeDirs)
+	if err != nil {
+		send(loadProgressMsg{err: err})
+		return
+	}
+	send(loadProgressMsg{total: len(files)})
+
+	results := parseConversations(cache, files, cutoff, maxSize)
+	tick := time.NewTicker(loadBatchInterval)
+	defer tick.Stop()
+	var batch []Conversation
+	done := 0
+	for {
+		select {
+		case conv, ok := <-results:
+			if !ok {
+				cache.retain(files)
+				_ = cache.save()
+				send(loadProgressMsg{items: buildItems(batch), done: done, total: len(files)})
+				return
+			}
+			done++
+			if conv != nil {
+				batch = append(batch, *conv)
+			}
+		case <-tick.C:
+			// Nothing new to list: skip the message rather than make the TUI
+			// refilter for a counter update. The count catches up with the
+			// next batch.
+			if len(batch) == 0 {
+				continue
+			}
+			send(loadProgressMsg{items: buildItems(batch), done: done, total: len(files)})
+			batch = nil
+		}
+	}
+}
+
+// ============================================================================
+// Live updates - watch the projects tree while the TUI is open
+// ============================================================================
+
+// convsChangedMsg carries conversations created, grown or deleted on disk
+// since the list was built. Like the loader, the watcher builds the items, so
+// a huge active session is re-indexed off the UI goroutine.
+type convsChangedMsg struct {
+	updated []listItem
+	removed []string // file paths
+}
+
+// watchDebounce coalesces the burst of writes Claude makes during a turn into
+// one reparse.
+const watchDebounce = 300 * time.Millisecond
+
+// watchProjects watches the projects tree (inotify on Linux, kqueue on macOS)
+// and sends a convsChangedMsg for each debounced batch of session file changes.
+// Changed files are reparsed through cache, so a growing session only costs
+// its new lines. The returned stop func ends the watch.
+func watchProjects(cache *convCache, cutoff time.Time, maxSize int64, excludeDirs []string, send func(tea.Msg)) (stop func(), err error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make(map[string]bool)
+	// fsnotify is not recursive: watch every searched directory, and pick up
+	// sessions already written into a directory created after the walk.
+	addTree := func(dir string, queue bool) {
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if info.IsDir() {
+				if skipDir(info, excludeDirs) {
+					return filepath.SkipDir
+				}
+				w.Add(path)
+			} else if queue && isConversationFile(path) {
+				pending[path] = true
+			}
+			return nil
+		})
+	}
+	addTree(getProjectsDir(), false)
+
+	go func() {
+		var flush <-chan time.Time
+		for {
+			select {
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if ev.Has(fsnotify.Create) {
+					if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+						addTree(ev.Name, true)
+					}
+				}
+				if isConversationFile(ev.Name) {
+					pending[ev.Name] = true
+				}
+				if len(pending) > 0 && flush == nil {
+					flush = time.After(watchDebounce)
+				}
+			case _, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+			case <-flush:
+				flush = nil
+				var msg convsChangedMsg
+				var updated []Conversation
+				for path := range pending {
+					conv, err := cache.parseConversationFile(path, cutoff, maxSize)
+					switch {
+					case os.IsNotExist(err):
+						msg.removed = append(msg.removed, path)
+					case err != nil:
+						// Unreadable right now; the next write retries it.
+					case conv == nil:
+						msg.removed = append(msg.removed, path) // filtered out or no text
+					default:
+						updated = append(updated, *conv)
+					}
+				}
+				msg.updated = buildItems(updated)
+				clear(pending)
+				send(msg)
+			}
+		}
 	}()
+	return func() { w.Close() }, nil
+}
 
-	var conversations []Conversation
-	for conv := range results {
-		conversations = append(conversations, *conv)
+// applyChanges merges live updates into the list. The selected conversation
+// keeps the cursor (even as rows shift around it), and the query is re-applied
+// so new conversations only show up if they match.
+func (m *model) applyChanges(updated []listItem, removed []string) {
+	selectedID := m.selectedID()
+	pendingID := ""
+	if m.confirmDelete && !m.batch && m.deleteIndex < len(m.filtered) {
+		pendingID = m.filtered[m.deleteIndex].conv.SessionID
+	} else if m.confirmPrune && !m.batch && m.pruneIndex < len(m.filtered) {
+		pendingID = m.filtered[m.pruneIndex].conv.SessionID
 	}
 
-	sort.Slice(conversations, func(i, j int) bool {
-		return conversations[i].LastTimestamp > conversations[j].LastTimestamp
+	gone := make(map[string]bool, len(removed)+len(updated))
+	for _, path := range removed {
+		gone[path] = true
+	}
+	for _, item := range updated {
+		gone[item.conv.FilePath] = true // replaced below
+		if m.hits != nil {
+			delete(m.hits.byID, item.conv.SessionID)
+		}
+	}
+	items := make([]listItem, 0, len(m.items)+len(updated))
+	for _, item := range m.items {
+		if !gone[item.conv.FilePath] {
+			items = append(items, item)
+		}
+	}
+	items = append(items, updated...)
+	if m.viewer != nil {
+		// Keep the open transcript growing along with its session.
+		for _, item := range updated {
+			if item.conv.SessionID == m.viewer.conv.SessionID {
+				m.viewer.conv = item.conv
+				m.viewer.render()
+			}
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].conv.LastTimestamp > items[j].conv.LastTimestamp
 	})
+	m.items = items
+	if m.preview != nil {
+		m.preview.key = "" // the selected conversation may have grown
+	}
+	// Marks of conversations deleted elsewhere go with them.
+	for id := range m.marked {
+		if !slices.ContainsFunc(items, func(item listItem) bool { return item.conv.SessionID == id }) {
+			delete(m.marked, id)
+		}
+	}
+	if m.batch && len(m.marked) == 0 {
+		m.confirmDelete, m.confirmPrune = false, false // an export keeps what it took
+	}
 
-	return conversations, nil
+	scroll := m.previewScroll
+	m.lastQuery = nil // new items: rescan everything, no narrowing
+	m.updateFilter()
+	m.cursor = 0
+	if m.selectID(selectedID) {
+		m.previewScroll = min(scroll, m.maxPreviewScroll())
+	}
+
+	// Keep a pending confirmation pointed at the same conversation, or drop it
+	// if that conversation disappeared.
+	if pendingID != "" {
+		idx := -1
+		for i, item := range m.filtered {
+			if item.conv.SessionID == pendingID {
+				idx = i
+			}
+		}
+		m.deleteIndex, m.pruneIndex = idx, idx
+		if idx < 0 {
+			m.confirmDelete, m.confirmPrune = false, false
+		}
+	}
 }
 
 func formatTimestamp(ts string) string {
@@ -922,14 +4500,10 @@ func formatBytes(n int64) string {
 
 func truncate(s string, maxLen int) string {
 	s = strings.Join(strings.Fields(s), " ")
-	r := []rune(s)
-	if len(r) <= maxLen {
-		return s
-	}
 	if maxLen < 3 {
-		return string(r[:maxLen]) // no room for the ellipsis
+		return runewidth.Truncate(s, maxLen, "") // no room for the ellipsis
 	}
-	return string(r[:maxLen-3]) + "..."
+	return runewidth.Truncate(s, maxLen, "...")
 }
 
 // getTopic returns the session name (custom/ai title), else first user message, else session ID
@@ -938,7 +4512,7 @@ func getTopic(conv Conversation) string {
 		return conv.Title
 	}
 	for _, msg := range conv.Messages {
-		if msg.Role == "user" {
+		if msg.Role == "user" && msg.dialogue() {
 			return msg.Text
 		}
 	}
@@ -963,13 +4537,16 @@ func (m *model) deleteConversation() {
 	// Remove from filtered slice
 	m.filtered = append(m.filtered[:m.deleteIndex], m.filtered[m.deleteIndex+1:]...)
 
-	// Remove from items slice (find by SessionID)
+	// Remove from items slice (find by SessionID), and from the matches the
+	// facet sidebar narrows
 	for i, item := range m.items {
 		if item.conv.SessionID == conv.SessionID {
 			m.items = append(m.items[:i], m.items[i+1:]...)
 			break
 		}
 	}
+	m.matched = slices.DeleteFunc(m.matched, func(item listItem) bool { return item.conv.SessionID == conv.SessionID })
+	delete(m.marked, conv.SessionID)
 
 	// Adjust cursor
 	if len(m.filtered) == 0 {
@@ -1000,18 +4577,177 @@ func (m *model) pruneConversation() {
 		return
 	}
 
+	m.pruned(conv, st)
+	m.errorMsg = ""
+}
+
+// pruned refreshes conv's displayed size after a prune.
+func (m *model) pruned(conv Conversation, st pruneStats) {
 	newSize := conv.Size - (st.bytesIn - st.bytesOut)
 	if info, e := os.Stat(conv.FilePath); e == nil {
 		newSize = info.Size()
 	}
-	for _, items := range [][]listItem{m.items, m.filtered} {
+	for _, items := range [][]listItem{m.items, m.matched, m.filtered} {
 		for i := range items {
 			if items[i].conv.SessionID == conv.SessionID {
 				items[i].conv.Size = newSize
 			}
 		}
 	}
+}
+
+// toggleMark marks or unmarks a conversation for a batch delete/prune.
+func (m *model) toggleMark(id string) {
+	if m.marked[id] {
+		delete(m.marked, id)
+		return
+	}
+	if m.marked == nil {
+		m.marked = make(map[string]bool)
+	}
+	m.marked[id] = true
+}
+
+// markAll marks every listed conversation, or unmarks them if all already are.
+// Marks outside the list are left alone.
+func (m *model) markAll() {
+	all := len(m.filtered) > 0
+	for _, item := range m.filtered {
+		all = all && m.marked[item.conv.SessionID]
+	}
+	for _, item := range m.filtered {
+		if m.marked[item.conv.SessionID] == all {
+			m.toggleMark(item.conv.SessionID)
+		}
+	}
+}
+
+// markedConvs are the marked conversations in list order. Marks persist across
+// searches, so they need not all be listed.
+func (m model) markedConvs() []Conversation {
+	var convs []Conversation
+	for _, item := range m.items {
+		if m.marked[item.conv.SessionID] {
+			convs = append(convs, item.conv)
+		}
+	}
+	return convs
+}
+
+// markedSize is the total file size of the marked conversations.
+func (m model) markedSize() int64 {
+	var n int64
+	for _, conv := range m.markedConvs() {
+		n += conv.Size
+	}
+	return n
+}
+
+// deleteMarked removes the marked conversations from disk and UI. The cursor
+// stays on its conversation, or at its position if that one was deleted. A
+// conversation that can't be deleted stays marked, so the batch can be retried.
+func (m *model) deleteMarked() {
+	m.confirmDelete = false
+	gone := make(map[string]bool)
+	var failed []error
+	for _, conv := range m.markedConvs() {
+		if err := os.Remove(conv.FilePath); err != nil && !os.IsNotExist(err) {
+			failed = append(failed, err)
+			continue
+		}
+		gone[conv.SessionID] = true
+		delete(m.marked, conv.SessionID)
+	}
+
+	id, cursor := m.selectedID(), m.cursor
+	m.items = slices.DeleteFunc(m.items, func(item listItem) bool { return gone[item.conv.SessionID] })
+	m.lastQuery = nil
+	m.updateFilter()
+	if !m.selectID(id) {
+		m.cursor = max(0, min(cursor, len(m.filtered)-1))
+		m.previewScroll = 0
+	}
+
+	m.errorMsg = ""
+	if len(failed) > 0 {
+		m.errorMsg = fmt.Sprintf("Delete failed for %d of %d: %v", len(failed), len(failed)+len(gone), failed[0])
+	}
+}
+
+// pruneMarked prunes the marked conversations in place, unmarking each one
+// pruned. As with deleteMarked, failures stay marked.
+// ponytail: synchronous, like pruneConversation, so the UI waits for the batch.
+func (m *model) pruneMarked() {
+	m.confirmPrune = false
+	var failed []error
+	done := 0
+	for _, conv := range m.markedConvs() {
+		st, err := pruneFile(conv.FilePath, true, pruneOpts{dropSnapshots: true, stripToolResults: true})
+		if err != nil {
+			failed = append(failed, err)
+			continue
+		}
+		m.pruned(conv, st)
+		delete(m.marked, conv.SessionID)
+		done++
+	}
+
+	m.errorMsg = ""
+	if len(failed) > 0 {
+		m.errorMsg = fmt.Sprintf("Prune failed for %d of %d: %v", len(failed), len(failed)+done, failed[0])
+	}
+}
+
+// exportConversations writes each conversation of the pending export to
+// exportDir as <session>.md (see exportMarkdown), unmarking each one written.
+// As with deleteMarked, failures stay marked.
+func (m *model) exportConversations() {
+	convs := m.exports
+	m.confirmExport, m.exports = false, nil
 	m.errorMsg = ""
+	if err := os.MkdirAll(m.exportDir, 0755); err != nil {
+		m.errorMsg = fmt.Sprintf("Export failed: %v", err)
+		return
+	}
+	var failed []error
+	done := 0
+	for _, conv := range convs {
+		path := filepath.Join(m.exportDir, conv.SessionID+".md")
+		if err := os.WriteFile(path, []byte(exportMarkdown(conv)), 0644); err != nil {
+			failed = append(failed, err)
+			continue
+		}
+		delete(m.marked, conv.SessionID)
+		done++
+	}
+	if len(failed) > 0 {
+		m.errorMsg = fmt.Sprintf("Export failed for %d of %d: %v", len(failed), len(failed)+done, failed[0])
+	}
+}
+
+// exportMarkdown renders a conversation's dialogue as Markdown: its topic and
+// details, then each message under its role and time. Tool traffic and
+// thinking are left out, as from MSGS.
+func exportMarkdown(conv Conversation) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", truncate(getTopic(conv), 80))
+	fmt.Fprintf(&b, "- Session: `%s`\n", conv.SessionID)
+	fmt.Fprintf(&b, "- Project: `%s`\n", conv.Cwd)
+	if len(conv.Branches) > 0 {
+		fmt.Fprintf(&b, "- Branches: %s\n", strings.Join(conv.Branches, ", "))
+	}
+	fmt.Fprintf(&b, "- Active: %s - %s\n", formatTimestamp(conv.FirstTimestamp), formatTimestamp(conv.LastTimestamp))
+	for _, msg := range conv.Messages {
+		if !msg.dialogue() {
+			continue
+		}
+		role := "User"
+		if msg.Role != "user" {
+			role = "Claude"
+		}
+		fmt.Fprintf(&b, "\n## %s · %s\n\n%s\n", role, formatTimestamp(msg.Ts), msg.Text)
+	}
+	return b.String()
 }
 
 // buildItems creates list items from conversations
@@ -1019,6 +4755,7 @@ func buildItems(conversations []Conversation) []listItem {
 	items := make([]listItem, 0, len(conversations))
 
 	for _, conv := range conversations {
+		conv.repo = repoRoot(conv.Cwd) // memoised; not cached, so it follows the disk
 		// Build search text from all content
 		var searchParts []string
 		searchParts = append(searchParts, conv.SessionID)
@@ -1029,22 +4766,215 @@ func buildItems(conversations []Conversation) []listItem {
 
 		// Include assistant messages too so a conversation is findable by
 		// what Claude said, matching the HITS column and preview which already
-		// count all messages.
-		for _, msg := range conv.Messages {
-			searchParts = append(searchParts, msg.Text)
+		// count all messages. Tool traffic and thinking are indexed apart, and
+		// only while they are searched (see listItem.index).
+		var dialogueAt []int // where each dialogue part is in conv.Messages
+		for i, msg := range conv.Messages {
+			if msg.dialogue() {
+				searchParts = append(searchParts, msg.Text)
+				dialogueAt = append(dialogueAt, i)
+			}
 		}
 
-		searchText := strings.Join(searchParts, " ")
-		items = append(items, listItem{
+		// Message text is NFC already; the path may not be (macOS keeps
+		// file names decomposed). Folding decomposes anyway, so each part
+		// folds on its own and the messages keep theirs for fuzzy scoring.
+		searchLower, folded := foldJoin(searchParts)
+		item := listItem{
 			conv:        conv,
-			searchText:  searchText,
-			searchLower: strings.ToLower(searchText),
-		})
+			searchText:  norm.NFC.String(strings.Join(searchParts, " ")),
+			searchLower: searchLower,
+			msgLower:    make([]string, len(conv.Messages)),
+		}
+		dialogue := folded[len(folded)-len(dialogueAt):]
+		for k, i := range dialogueAt {
+			item.msgLower[i] = dialogue[k]
+		}
+		items = append(items, item)
 	}
 
 	return items
 }
 
+// ============================================================================
+// Cache - parsed conversations persisted between runs
+// ============================================================================
+
+// cacheVersion is bumped whenever parsing changes what a Conversation holds, so
+// entries written by an older parser are discarded rather than trusted.
+const cacheVersion = 10
+
+// getCacheDir returns the directory holding the parse cache ("" disables it).
+// Declared as a variable so it can be overridden in tests
+var getCacheDir = func() string {
+	dir, err := os.UserCacheDir() // $XDG_CACHE_HOME, ~/.cache, ~/Library/Caches
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "ccs")
+}
+
+// cacheEntry is one parsed file, valid as-is while the file's size and mtime
+// match and State.Offset has consumed all of it, and resumable from
+// State.Offset otherwise (the file has grown, or ended mid-line).
+type cacheEntry struct {
+	Size    int64 // file size the parse stopped at
+	ModTime int64 // UnixNano
+	State   *parseState
+}
+
+// convCache maps conversation file paths to their parsed form. A nil *convCache
+// is valid and caches nothing. Workers share one cache, hence the mutex.
+type convCache struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]*cacheEntry
+	dirty   bool
+}
+
+// cacheFile is the on-disk form of convCache.
+type cacheFile struct {
+	Version int
+	Entries map[string]*cacheEntry
+}
+
+// loadCache reads the parse cache. A missing, unreadable or outdated cache
+// yields an empty one - it is only ever an optimisation. Returns nil when
+// caching is disabled.
+func loadCache() *convCache {
+	dir := getCacheDir()
+	if dir == "" {
+		return nil
+	}
+	c := &convCache{
+		path:    filepath.Join(dir, "conversations.gob"),
+		entries: make(map[string]*cacheEntry),
+	}
+	f, err := os.Open(c.path)
+	if err != nil {
+		return c
+	}
+	defer f.Close()
+	var cf cacheFile
+	if err := gob.NewDecoder(bufio.NewReader(f)).Decode(&cf); err != nil || cf.Version != cacheVersion {
+		c.dirty = true // rewrite the stale/corrupt file on save
+		return c
+	}
+	if cf.Entries != nil {
+		c.entries = cf.Entries
+	}
+	return c
+}
+
+// parseConversationFile is parseConversationFile backed by the cache: an
+// unchanged, fully consumed file is served without being read, and a
+// file that has only been appended to is parsed from where the last parse
+// stopped. Anything else - a new, shrunk or rewritten file - is parsed whole.
+func (c *convCache) parseConversationFile(path string, cutoff time.Time, maxSize int64) (*Conversation, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if skipFile(info, cutoff, maxSize) {
+		return nil, nil
+	}
+	e := c.lookup(path)
+	if e != nil && e.Size == info.Size() && e.State.Offset == info.Size() && e.ModTime == info.ModTime().UnixNano() {
+		return e.State.conversation(), nil
+	}
+	var st *parseState
+	if e != nil && e.State.resumable(info) {
+		st = e.State.clone()
+	} else {
+		st = newParseState(path)
+	}
+	if err := st.parseFile(info); err != nil {
+		return nil, err
+	}
+	c.store(path, &cacheEntry{Size: info.Size(), ModTime: info.ModTime().UnixNano(), State: st})
+	return st.conversation(), nil
+}
+
+// lookup returns the cached entry for path, if any.
+func (c *convCache) lookup(path string) *cacheEntry {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries[path]
+}
+
+func (c *convCache) store(path string, e *cacheEntry) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[path] = e
+	c.dirty = true
+}
+
+// retain drops entries for files that no longer exist (or are no longer
+// walked), so the cache doesn't grow forever.
+func (c *convCache) retain(paths []string) {
+	if c == nil {
+		return
+	}
+	keep := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		keep[p] = true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for p := range c.entries {
+		if !keep[p] {
+			delete(c.entries, p)
+			c.dirty = true
+		}
+	}
+}
+
+// save writes the cache if anything changed. It writes a temp file and renames
+// it into place, so concurrent ccs processes never see a torn cache.
+// ponytail: last writer wins - a concurrent run's fresh entries may be dropped,
+// which only costs a reparse next launch.
+func (c *convCache) save() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+	dir := filepath.Dir(c.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "conversations-*.tmp")
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(tmp)
+	err = gob.NewEncoder(bw).Encode(cacheFile{Version: cacheVersion, Entries: c.entries})
+	if err == nil {
+		err = bw.Flush()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
 // ============================================================================
 // Prune - shrink conversation files by removing duplicate / redundant data
 // ============================================================================
@@ -1215,7 +5145,12 @@ func runPrune(args []string) {
 		case a == "--no-tool-results":
 			opts.stripToolResults = false
 		case strings.HasPrefix(a, "--min-size="):
-			fmt.Sscanf(strings.TrimPrefix(a, "--min-size="), "%d", &minSizeMB)
+			n, err := flagInt(strings.TrimPrefix(a, "--min-size="))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v (try ccs prune --help)\n", a, err)
+				os.Exit(2)
+			}
+			minSizeMB = int64(n)
 		default:
 			fmt.Fprintf(os.Stderr, "unknown prune flag: %s (try ccs prune --help)\n", a)
 			os.Exit(2)
@@ -1340,6 +5275,23 @@ Flags:
   --max-size=N     Max file size in MB (default: 1024, 0 = no limit)
   --all            Include everything (same as --max-age=0 --max-size=0)
   --exclude=a,b    Exclude dirs containing these strings (default: observer-sessions)
+  --no-cache       Reparse every file, bypassing the parse cache
+  --fuzzy          Start in fuzzy search mode (toggle with Alt+Z)
+  --regex          Start in regex search mode (toggle with Alt+R)
+  --tools          Also search tool calls and results (toggle with Alt+T); of one
+                   over 64KB only the first and last 32KB are searched
+  --thinking       Also search Claude's thinking blocks (toggle with Alt+K)
+  --context=N      Characters shown around each match in long messages (default: 150)
+  --here           Only conversations from the current directory's project or git
+                   repository, worktrees included (toggle with Alt+H)
+  --branch-column  Show each conversation's git branch (toggle with Alt+V)
+  --raw            Show messages as written, without markdown styling (toggle with Alt+M)
+  --layout=MODE    auto (default: preview beside the list on terminals 160+ wide),
+                   stacked or side (cycle with Alt+L)
+  --split=N        List pane's share of the screen in percent, 15-85 (default: 30
+                   stacked, 50 side by side; adjust with Alt+=/Alt+-)
+  --case=MODE      smart (default: case-sensitive if a term has uppercase),
+                   sensitive or ignore (cycle with Alt+C)
   --dump [query]   Debug: print all search items (with optional highlighting)
 
 Examples:
@@ -1350,18 +5302,209 @@ Examples:
   ccs -- --plan                      Resume with plan mode
   ccs buyer -- --plan                Search "buyer", resume with plan mode
 
+Search syntax (space-separated terms must all match; a term with uppercase
+matches case):
+  "exact phrase"   Match words together, in order
+  /regex/          Match a regular expression
+  a OR b           Match either term
+  -term            Exclude conversations containing term (also -"phrase", -project:x)
+  project:NAME     Working directory (or its repository) contains NAME
+  branch:NAME      Ran on a git branch containing NAME
+  title:TEXT       Session name (or first message) contains TEXT
+  session:ID       Session ID starts with ID
+  role:user        Match text only in your messages (or role:assistant)
+  after:DATE       Active on or after DATE (2026-01-31, 2026-01 or 2026)
+  before:DATE      Started before DATE
+  size:>50MB       File size (>, >=, <, <=, =; bare value means >=)
+  msgs:>100        Message count (same comparisons)
+
 Key bindings:
   ↑/↓, Ctrl+P/N   Navigate list
   Enter           Select and resume conversation
+  Tab             Mark/unmark conversation (Ctrl+D, Ctrl+R and Ctrl+X then act on the marked)
+  Alt+A           Mark all listed conversations (again to unmark them)
   Ctrl+D          Delete conversation (with confirmation)
   Ctrl+R          Prune conversation - shrink it losslessly (with confirmation)
+  Ctrl+X          Export conversation as Markdown to ./ccs-export (with confirmation)
   Ctrl+J/K        Scroll preview
+  Alt+N/P         Jump to the next/previous match in the preview
+  Ctrl+O          Open the conversation full screen (/ searches, Esc returns)
   Ctrl+U          Clear search
+  Alt+Z           Toggle fuzzy matching (in-order characters, best matches first)
+  Alt+R           Toggle regex mode (every word or "phrase" is a regex)
+  Alt+T           Toggle searching tool calls (commands, file paths) and results
+  Alt+C           Cycle case matching: smart, sensitive, ignore
+  Alt+K           Toggle searching thinking blocks
+  Alt+E           Expand/collapse thinking blocks in the preview
+  Alt+M           Toggle markdown styling (raw text is easier to copy)
+  Alt+H           Toggle showing only the current project (--here)
+  Alt+V           Toggle the BRANCH column
+  Alt+G           Facet sidebar: filter by project, branch, model, month
+                  (↑/↓ and Enter choose values, Esc returns to the list,
+                  Alt+G again closes it)
+  Alt+L           Cycle layout: auto, stacked, side by side
+  Alt+=/Alt+-     Grow/shrink the list pane
+  Ctrl+S          Cycle the sort: last activity, relevance (BM25), first activity,
+                  size, messages, hits, title
+  Alt+S           Reverse the sort direction
   Esc, Ctrl+C     Quit
 
 `, version)
 }
 
+// searchConfig is what the search command's flags set.
+type searchConfig struct {
+	maxAgeDays  int
+	maxSizeMB   int64
+	excludeDirs []string
+	noCache     bool
+	opts        searchOpts
+	display     previewOpts
+	layout      layoutMode
+	listPct     int
+	here        bool
+	showBranch  bool
+}
+
+// searchFlag is one of the search command's flags. A name ending in "=" takes
+// a value, which set receives ("" for a switch).
+type searchFlag struct {
+	name string
+	set  func(cfg *searchConfig, val string) error
+}
+
+// searchFlags both parses the flags and tells them apart from the filter
+// query, so the two can't drift apart. --help, --version and --dump are
+// handled before these.
+var searchFlags = []searchFlag{
+	{"--all", func(cfg *searchConfig, _ string) error {
+		cfg.maxAgeDays, cfg.maxSizeMB = 0, 0
+		return nil
+	}},
+	{"--max-age=", func(cfg *searchConfig, val string) (err error) {
+		cfg.maxAgeDays, err = flagInt(val)
+		return err
+	}},
+	{"--max-size=", func(cfg *searchConfig, val string) error {
+		n, err := flagInt(val)
+		cfg.maxSizeMB = int64(n)
+		return err
+	}},
+	{"--exclude=", func(cfg *searchConfig, val string) error {
+		cfg.excludeDirs = strings.Split(val, ",")
+		return nil
+	}},
+	{"--no-cache", func(cfg *searchConfig, _ string) error {
+		cfg.noCache = true
+		return nil
+	}},
+	{"--fuzzy", func(cfg *searchConfig, _ string) error {
+		cfg.opts.fuzzy = true
+		return nil
+	}},
+	{"--regex", func(cfg *searchConfig, _ string) error {
+		cfg.opts.regex = true
+		return nil
+	}},
+	{"--tools", func(cfg *searchConfig, _ string) error {
+		cfg.opts.tools = true
+		return nil
+	}},
+	{"--thinking", func(cfg *searchConfig, _ string) error {
+		cfg.opts.thinking = true
+		return nil
+	}},
+	{"--case=", func(cfg *searchConfig, val string) error {
+		mode, ok := caseModeFlags[val]
+		if !ok {
+			return errors.New("expected smart, sensitive or ignore")
+		}
+		cfg.opts.caseMode = mode
+		return nil
+	}},
+	{"--context=", func(cfg *searchConfig, val string) (err error) {
+		cfg.display.context, err = flagInt(val)
+		return err
+	}},
+	{"--raw", func(cfg *searchConfig, _ string) error {
+		cfg.display.raw = true
+		return nil
+	}},
+	{"--here", func(cfg *searchConfig, _ string) error {
+		cfg.here = true
+		return nil
+	}},
+	{"--branch-column", func(cfg *searchConfig, _ string) error {
+		cfg.showBranch = true
+		return nil
+	}},
+	{"--layout=", func(cfg *searchConfig, val string) error {
+		for mode, name := range layoutNames {
+			if name == val {
+				cfg.layout = mode
+				return nil
+			}
+		}
+		return errors.New("expected auto, stacked or side")
+	}},
+	{"--split=", func(cfg *searchConfig, val string) (err error) {
+		cfg.listPct, err = flagInt(val)
+		if err == nil && (cfg.listPct < listPctMin || cfg.listPct > listPctMax) {
+			err = fmt.Errorf("expected a percentage from %d to %d", listPctMin, listPctMax)
+		}
+		return err
+	}},
+}
+
+// flagInt parses a flag's value as a whole number of at least 0.
+func flagInt(val string) (int, error) {
+	n, err := strconv.Atoi(val)
+	if err != nil || n < 0 {
+		return 0, errors.New("expected a whole number")
+	}
+	return n, nil
+}
+
+// parseSearchArgs parses the search command's arguments: flags from
+// searchFlags, the first other non-flag argument as the filter query, and
+// everything after "--" as flags for claude.
+func parseSearchArgs(args []string) (cfg searchConfig, filterQuery string, claudeFlags []string, err error) {
+	cfg = searchConfig{
+		maxAgeDays:  60,   // Default to 60 days
+		maxSizeMB:   1024, // Default to 1GB
+		excludeDirs: []string{"observer-sessions"},
+	}
+	for i, arg := range args {
+		if arg == "--" {
+			return cfg, filterQuery, args[i+1:], nil
+		}
+		if f, val, ok := lookupSearchFlag(arg); ok {
+			if err := f.set(&cfg, val); err != nil {
+				return cfg, "", nil, fmt.Errorf("%s: %v", arg, err)
+			}
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") && filterQuery == "" {
+			filterQuery = arg
+		}
+	}
+	return cfg, filterQuery, nil, nil
+}
+
+// lookupSearchFlag finds arg's flag in searchFlags, with its value.
+func lookupSearchFlag(arg string) (searchFlag, string, bool) {
+	for _, f := range searchFlags {
+		if strings.HasSuffix(f.name, "=") {
+			if val, ok := strings.CutPrefix(arg, f.name); ok {
+				return f, val, true
+			}
+		} else if arg == f.name {
+			return f, "", true
+		}
+	}
+	return searchFlag{}, "", false
+}
+
 func main() {
 	args := os.Args[1:]
 
@@ -1381,34 +5524,24 @@ func main() {
 		}
 	}
 
-	// Parse flags
-	maxAgeDays := 60        // Default to 60 days
-	maxSizeMB := int64(1024) // Default to 1GB
-	excludeDirs := []string{"observer-sessions"}
-	for _, arg := range args {
-		if arg == "--all" {
-			maxAgeDays = 0
-			maxSizeMB = 0
-		} else if strings.HasPrefix(arg, "--max-age=") {
-			val := strings.TrimPrefix(arg, "--max-age=")
-			fmt.Sscanf(val, "%d", &maxAgeDays)
-		} else if strings.HasPrefix(arg, "--max-size=") {
-			val := strings.TrimPrefix(arg, "--max-size=")
-			fmt.Sscanf(val, "%d", &maxSizeMB)
-		} else if strings.HasPrefix(arg, "--exclude=") {
-			val := strings.TrimPrefix(arg, "--exclude=")
-			excludeDirs = strings.Split(val, ",")
-		}
+	cfg, filterQuery, claudeFlags, err := parseSearchArgs(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v (try ccs --help)\n", err)
+		os.Exit(2)
+	}
+	if cfg.noCache {
+		getCacheDir = func() string { return "" }
 	}
 
 	// Convert to bytes (0 means no limit)
-	maxSize := maxSizeMB * 1024 * 1024
+	maxSize := cfg.maxSizeMB * 1024 * 1024
 
 	// Calculate cutoff time (0 means no limit)
 	var cutoff time.Time
-	if maxAgeDays > 0 {
-		cutoff = time.Now().AddDate(0, 0, -maxAgeDays)
+	if cfg.maxAgeDays > 0 {
+		cutoff = time.Now().AddDate(0, 0, -cfg.maxAgeDays)
 	}
+	excludeDirs := cfg.excludeDirs
 
 	// Debug mode - dump search lines
 	for i, arg := range args {
@@ -1430,23 +5563,6 @@ func main() {
 		}
 	}
 
-	// Parse args: positional arg is filter, args after -- go to claude
-	var claudeFlags []string
-	var filterQuery string
-	for i, arg := range args {
-		if arg == "--" {
-			claudeFlags = args[i+1:]
-			break
-		}
-		// Skip our flags when looking for filter query
-		if arg == "--all" || strings.HasPrefix(arg, "--max-age=") || strings.HasPrefix(arg, "--max-size=") || strings.HasPrefix(arg, "--exclude=") {
-			continue
-		}
-		if !strings.HasPrefix(arg, "-") && filterQuery == "" {
-			filterQuery = arg
-		}
-	}
-
 	projectsDir := getProjectsDir()
 	if _, err := os.Stat(projectsDir); os.IsNotExist(err) {
 		fmt.Fprintf(os.Stderr, "Projects directory not found: %s\n", projectsDir)
@@ -1454,33 +5570,47 @@ func main() {
 		os.Exit(1)
 	}
 
-	fmt.Fprint(os.Stderr, "Loading conversations...")
-	conversations, err := getConversations(cutoff, maxSize, excludeDirs)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "\rError loading conversations: %v\n", err)
-		os.Exit(1)
-	}
-	fmt.Fprint(os.Stderr, "\r                         \r")
-
-	if len(conversations) == 0 {
-		fmt.Fprintf(os.Stderr, "No conversations found\n")
-		os.Exit(1)
-	}
-
-	items := buildItems(conversations)
-	if len(items) == 0 {
-		fmt.Fprintf(os.Stderr, "No searchable messages found\n")
-		os.Exit(1)
-	}
-
 	// Run TUI. Mouse reporting is intentionally NOT enabled: under a heavy
 	// frame the terminal emits mouse-wheel reports faster than bubbletea reads
 	// them, and the fragmented sequences leak into the search box as text.
 	// Scrolling is keyboard-only (arrows / Ctrl+J/K / PgUp/PgDn).
-	m := initialModel(items, filterQuery, claudeFlags)
+	m := initialModel(nil, filterQuery, claudeFlags)
+	m.opts = cfg.opts
+	m.display = cfg.display
+	m.layout, m.listPct = cfg.layout, cfg.listPct
+	m.showBranch = cfg.showBranch
+	if wd, err := os.Getwd(); err == nil {
+		m.scope = newHereScope(wd)
+		m.here = cfg.here
+	}
+	m.updateFilter()
+	m.loading = true
 	p := tea.NewProgram(m, tea.WithAltScreen())
 
+	// The TUI opens straight away; the cache is read, and conversations stream
+	// in from a background loader (recent files first), while the user types.
+	type background struct {
+		cache     *convCache
+		stopWatch func()
+	}
+	started := make(chan background, 1)
+	go func() {
+		cache := loadCache()
+		// Live updates are best-effort: without a watcher the list is just static.
+		stopWatch, err := watchProjects(cache, cutoff, maxSize, excludeDirs, p.Send)
+		if err != nil {
+			stopWatch = func() {}
+		}
+		started <- background{cache, stopWatch}
+		loadInBackground(cache, cutoff, maxSize, excludeDirs, p.Send)
+	}()
 	finalModel, err := p.Run()
+	select {
+	case bg := <-started:
+		bg.stopWatch()
+		_ = bg.cache.save() // keep the offsets of sessions that grew while open
+	default: // quit before the cache was read: nothing new to keep
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -2,9 +2,12 @@ package main
 
 import (
 	"bytes"
+	"encoding/gob"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -13,6 +16,19 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 )
 
+// TestMain points the parse cache at a scratch directory so tests never read or
+// write the real user cache.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "ccs-cache-test")
+	if err != nil {
+		panic(err)
+	}
+	getCacheDir = func() string { return dir }
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 func TestTruncate(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -25,9 +41,12 @@ func TestTruncate(t *testing.T) {
 		{"needs truncation", "hello world", 8, "hello..."},
 		{"with newlines", "hello\nworld", 20, "hello world"},
 		{"multiple spaces", "hello   world", 20, "hello world"},
-		{"multibyte fits", "世界", 5, "世界"},
-		{"multibyte truncates on rune boundary", "世界世界世界", 5, "世界..."},
-		{"tiny maxLen no ellipsis", "世界世界", 2, "世界"},
+		{"wide runes fit", "世界", 5, "世界"},
+		{"wide runes count two cells", "世界世界世界", 7, "世界..."},
+		{"wide rune not split at the edge", "世界世界世界", 6, "世..."},
+		{"tiny maxLen no ellipsis", "世界世界", 2, "世"},
+		{"emoji count two cells", "😀😀😀😀", 6, "😀..."},
+		{"combining marks take no cells", "cafe\u0301 cafe\u0301", 9, "cafe\u0301 cafe\u0301"},
 	}
 
 	for _, tt := range tests {
@@ -50,8 +69,10 @@ func TestPadRight(t *testing.T) {
 		{"short string", "hello", 10, "hello     "},
 		{"exact length", "hello", 5, "hello"},
 		{"needs truncation", "hello world", 8, "hello wo"},
-		{"multibyte pads by rune count", "世界", 5, "世界   "},
-		{"multibyte truncates on rune boundary", "世界世界世界", 4, "世界世界"},
+		{"wide runes pad by cells", "世界", 5, "世界 "},
+		{"wide runes truncate by cells", "世界世界世界", 4, "世界"},
+		{"straddling wide rune becomes padding", "世界世界", 5, "世界 "},
+		{"zero-width runes take no cells", "e\u0301\u200d", 3, "e\u0301\u200d  "},
 	}
 
 	for _, tt := range tests {
@@ -96,6 +117,75 @@ func TestHighlightMatchesMultibyte(t *testing.T) {
 	}
 }
 
+func TestFold(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Café", "cafe"},
+		{"Cafe\u0301", "cafe"}, // decomposed accent
+		{"Straße", "strasse"},
+		{"İstanbul", "istanbul"},
+		{"\u212Aelvin", "kelvin"}, // Kelvin sign
+		{"ﬁle", "file"},
+		{"日本語", "日本語"},
+	}
+	for _, tt := range tests {
+		if got := fold(tt.in); got != tt.want {
+			t.Errorf("fold(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestItemsKeepEachMessageFolded(t *testing.T) {
+	conv := Conversation{SessionID: "s", Title: "Straße", Messages: []Message{
+		{Role: "user", Text: "Café ﬁle"},
+		{Role: "assistant", Kind: kindToolUse, Tool: "Bash", Text: "LS -LA"},
+		{Role: "assistant", Text: "日本語 İstanbul"},
+	}}
+	item := buildItems([]Conversation{conv})[0]
+	want := []string{"cafe file", "", "日本語 istanbul"}
+	if !slices.Equal(item.msgLower, want) {
+		t.Errorf("msgLower = %q, want %q", item.msgLower, want)
+	}
+	if item.searchLower != fold(item.searchText) {
+		t.Errorf("searchLower = %q, want fold(searchText) %q", item.searchLower, fold(item.searchText))
+	}
+
+	copied := item
+	item.index(searchOpts{tools: true})
+	if item.msgLower[1] != "ls -la" || item.toolLower != "ls -la" {
+		t.Errorf("with tools: msgLower[1] = %q, toolLower = %q", item.msgLower[1], item.toolLower)
+	}
+	if copied.msgLower[1] != "" {
+		t.Error("index wrote through to a copy of the item")
+	}
+	item.index(searchOpts{})
+	if item.msgLower[1] != "" || item.msgLower[0] != "cafe file" {
+		t.Errorf("tools dropped: msgLower = %q", item.msgLower)
+	}
+}
+
+func TestHighlightFoldedMatchesLandOnOriginalRunes(t *testing.T) {
+	tests := []struct{ text, query, want string }{
+		{"un Café noir", "cafe", "un \033[43;30mCafé\033[0m noir"},
+		{"İstanbul trip", "istanbul", "\033[43;30mİstanbul\033[0m trip"},
+		{"die Straße hier", "strasse", "die \033[43;30mStraße\033[0m hier"},
+		{"a ﬁle", "file", "a \033[43;30mﬁle\033[0m"},
+		{"naïve", "naive", "\033[43;30mnaïve\033[0m"},
+	}
+	for _, tt := range tests {
+		if got := highlight(tt.text, tt.query); got != tt.want {
+			t.Errorf("highlight(%q, %q) = %q, want %q", tt.text, tt.query, got, tt.want)
+		}
+	}
+
+	item := buildItems([]Conversation{{SessionID: "s1", Messages: []Message{{Role: "user", Text: "un café crème"}}}})[0]
+	for _, query := range []string{"cafe creme", "CAFÉ", "\"cafe crème\""} {
+		q := parseQueryOpts(query, searchOpts{caseMode: caseIgnore})
+		if !q.matchItem(item) || countHits(item.conv, q) != 1 {
+			t.Errorf("%q should match accent- and case-insensitively", query)
+		}
+	}
+}
+
 func TestFormatBytes(t *testing.T) {
 	tests := []struct {
 		n    int64
@@ -132,6 +222,32 @@ func TestFormatListItemShowsSize(t *testing.T) {
 	}
 }
 
+func TestListRowsAlignWithWideText(t *testing.T) {
+	topics := []string{"plain ascii topic", "日本語のタイトルがとても長い場合の表示テスト", "emoji 😀🎉 party", "cafe\u0301 with combining marks", "混合 mixed 😀 text"}
+	var convs []Conversation
+	for i, topic := range topics {
+		convs = append(convs, Conversation{
+			SessionID:     fmt.Sprintf("s%d", i),
+			Cwd:           "/home/u/プロジェクト名前" + strings.Repeat("长", i),
+			Title:         topic,
+			IsCustomTitle: i%2 == 0,
+			LastTimestamp: "2024-01-15T10:30:00Z",
+			Messages:      []Message{{Role: "user", Text: "hi"}},
+		})
+	}
+	m := initialModel(buildItems(convs), "", nil)
+	m.width, m.height = 100, 30
+	for _, item := range m.items {
+		row := m.formatListItem(item, false)
+		if w := ansiWidth(row); w != m.width-listIndent {
+			t.Errorf("row for %q is %d cells, want %d: %q", item.conv.Title, w, m.width-listIndent, row)
+		}
+		if w := ansiWidth(padRight("> "+m.formatListItem(item, true), m.width)); w != m.width {
+			t.Errorf("selected row for %q is %d cells, want %d", item.conv.Title, w, m.width)
+		}
+	}
+}
+
 func TestFormatTimestamp(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -409,6 +525,203 @@ func TestParseConversationFileAiTitleNotCustom(t *testing.T) {
 	}
 }
 
+func TestParseConversationFileToolCalls(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "tools.jsonl")
+	content := `{"type":"user","cwd":"/p","message":{"content":"run the tests"},"timestamp":"2024-01-15T10:00:00Z"}
+{"type":"assistant","message":{"content":[{"type":"text","text":"Running them."},{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"go test ./...","description":"Run tests"}}]},"timestamp":"2024-01-15T10:01:00Z"}
+{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"t1","content":[{"type":"text","text":"FAIL: TestParse"}]}]},"timestamp":"2024-01-15T10:02:00Z"}
+`
+	if err := os.WriteFile(testFile, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+	conv, err := parseConversationFile(testFile, time.Time{}, 0)
+	if err != nil || conv == nil {
+		t.Fatalf("parseConversationFile = %v, %v", conv, err)
+	}
+	if len(conv.Messages) != 4 || conv.messageCount() != 2 {
+		t.Fatalf("got %d messages (%d dialogue), want 4 (2)", len(conv.Messages), conv.messageCount())
+	}
+	call, result := conv.Messages[2], conv.Messages[3]
+	if call.Kind != kindToolUse || call.Tool != "Bash" || call.Role != "assistant" || call.Text != "go test ./...\nRun tests" {
+		t.Errorf("tool call = %+v", call)
+	}
+	if result.Kind != kindToolResult || result.Role != "user" || result.Text != "FAIL: TestParse" {
+		t.Errorf("tool result = %+v", result)
+	}
+	if conv.LastTimestamp != "2024-01-15T10:01:00Z" {
+		t.Errorf("LastTimestamp = %q, want the last dialogue message's", conv.LastTimestamp)
+	}
+	if got := getTopic(*conv); got != "run the tests" {
+		t.Errorf("topic = %q, tool results must not become the topic", got)
+	}
+}
+
+func TestParseConversationFileBranchesAndModels(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "git.jsonl")
+	content := `{"type":"user","cwd":"/p","gitBranch":"main","message":{"content":"start"},"timestamp":"2024-01-15T10:00:00Z"}
+{"type":"assistant","gitBranch":"main","message":{"model":"claude-sonnet-4","content":"ok"},"timestamp":"2024-01-15T10:01:00Z"}
+{"type":"user","gitBranch":"fix/login","message":{"content":"switched"},"timestamp":"2024-01-15T10:02:00Z"}
+{"type":"assistant","gitBranch":"fix/login","message":{"model":"<synthetic>","content":"interrupted"},"timestamp":"2024-01-15T10:03:00Z"}
+{"type":"assistant","gitBranch":"main","message":{"model":"claude-opus-4","content":"done"},"timestamp":"2024-01-15T10:04:00Z"}
+`
+	if err := os.WriteFile(testFile, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+	conv, err := parseConversationFile(testFile, time.Time{}, 0)
+	if err != nil || conv == nil {
+		t.Fatalf("parseConversationFile = %v, %v", conv, err)
+	}
+	if !slices.Equal(conv.Branches, []string{"main", "fix/login"}) {
+		t.Errorf("Branches = %q, want each branch once in order", conv.Branches)
+	}
+	if !slices.Equal(conv.Models, []string{"claude-sonnet-4", "claude-opus-4"}) {
+		t.Errorf("Models = %q, want real models only", conv.Models)
+	}
+}
+
+func TestToolsModeSearchesToolCalls(t *testing.T) {
+	items := buildItems([]Conversation{{
+		SessionID: "s1",
+		Messages: []Message{
+			{Role: "user", Text: "run the tests"},
+			{Role: "assistant", Kind: kindToolUse, Tool: "Bash", Text: "go test ./..."},
+			{Role: "user", Kind: kindToolResult, Text: "FAIL: TestParse"},
+		},
+	}})
+	m := initialModel(items, "TestParse", nil)
+	m.width, m.height = 120, 30
+	if len(m.filtered) != 0 {
+		t.Fatal("tool results should not be searched by default")
+	}
+	if m.items[0].toolText != "" {
+		t.Error("tool traffic should not be indexed while it isn't searched")
+	}
+	if strings.Contains(m.formatListItem(items[0], false), " 3 ") {
+		t.Error("MSGS should count dialogue only")
+	}
+
+	res, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'t'}, Alt: true})
+	m = res.(model)
+	if !m.opts.tools || len(m.filtered) != 1 {
+		t.Fatalf("alt+t should search tool traffic (tools=%v, %d results)", m.opts.tools, len(m.filtered))
+	}
+	if got := m.hitCount(m.filtered[0]); got != 1 {
+		t.Errorf("hit count = %d, want 1", got)
+	}
+	preview := strings.Join(m.previewLines(), "\n")
+	if !strings.Contains(preview, "Tool: Bash\033[0m `go test ./...`") || !strings.Contains(preview, "Result:") {
+		t.Errorf("preview should render tool calls and results:\n%s", preview)
+	}
+
+	m.textInput.SetValue("")
+	res, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'t'}, Alt: true})
+	m = res.(model)
+	if strings.Contains(strings.Join(m.previewLines(), "\n"), "Tool: Bash") {
+		t.Error("tool calls should be hidden from the preview when not searched")
+	}
+	if m.items[0].toolText != "" || m.items[0].indexed.tools {
+		t.Error("turning tool search off should drop its index")
+	}
+}
+
+func TestLongToolTextKeepsHeadAndTail(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "tools.jsonl")
+	output := "=== RUN TestAll\n" + strings.Repeat("ok  \tpkg/é\n", 20000) + "FAIL: TestParse"
+	result, _ := json.Marshal(output)
+	content := `{"type":"user","cwd":"/p","message":{"content":"run the tests"},"timestamp":"2024-01-15T10:00:00Z"}
+{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"t1","content":` + string(result) + `}]},"timestamp":"2024-01-15T10:00:30Z"}
+`
+	if err := os.WriteFile(testFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	conv, err := parseConversationFile(testFile, time.Time{}, 0)
+	if err != nil || conv == nil || len(conv.Messages) != 2 {
+		t.Fatalf("parseConversationFile = %+v, %v", conv, err)
+	}
+	text := conv.Messages[1].Text
+	if len(text) > toolTextMax+len("\n…\n") || !utf8.ValidString(text) {
+		t.Errorf("stored result is %d bytes (valid UTF-8: %v), want at most %d", len(text), utf8.ValidString(text), toolTextMax)
+	}
+	if !strings.HasPrefix(text, "=== RUN TestAll") || !strings.HasSuffix(text, "FAIL: TestParse") || !strings.Contains(text, "\n…\n") {
+		t.Errorf("stored result should keep the head and the tail: %q...%q", text[:40], text[len(text)-40:])
+	}
+
+	// A result of ordinary size, such as a file read, is kept whole.
+	file := strings.Repeat("line\n", 1000) + "needle\n" + strings.Repeat("line\n", 1000)
+	if got := capToolText(file); got != file {
+		t.Errorf("a %d-byte result should be kept whole, got %d bytes", len(file), len(got))
+	}
+}
+
+func TestThinkingBlocksSearchableAndCollapsed(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "thinking.jsonl")
+	content := `{"type":"user","cwd":"/p","message":{"content":"why is it slow"},"timestamp":"2024-01-15T10:00:00Z"}
+{"type":"assistant","message":{"content":[{"type":"thinking","thinking":"The index is missing,\nso every lookup scans.","signature":"x"}]},"timestamp":"2024-01-15T10:01:00Z"}
+{"type":"assistant","message":{"content":[{"type":"text","text":"Add an index."}]},"timestamp":"2024-01-15T10:02:00Z"}
+`
+	if err := os.WriteFile(testFile, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+	conv, err := parseConversationFile(testFile, time.Time{}, 0)
+	if err != nil || conv == nil {
+		t.Fatalf("parseConversationFile = %v, %v", conv, err)
+	}
+	if len(conv.Messages) != 3 || conv.Messages[1].Kind != kindThinking || conv.messageCount() != 2 {
+		t.Fatalf("messages = %+v", conv.Messages)
+	}
+
+	m := initialModel(buildItems([]Conversation{*conv}), "", nil)
+	m.width, m.height = 120, 30
+	preview := strings.Join(m.previewLines(), "\n")
+	if !strings.Contains(preview, "Thinking (2 lines, Alt+E to expand)") || strings.Contains(preview, "every lookup") {
+		t.Errorf("thinking should be collapsed by default:\n%s", preview)
+	}
+	res, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'e'}, Alt: true})
+	m = res.(model)
+	if !strings.Contains(strings.Join(m.previewLines(), "\n"), "every lookup scans") {
+		t.Error("alt+e should expand thinking blocks")
+	}
+
+	m.textInput.SetValue("lookup")
+	m.updateFilter()
+	if len(m.filtered) != 0 {
+		t.Fatal("thinking should not be searched by default")
+	}
+	if m.items[0].thinkText != "" {
+		t.Error("thinking should not be indexed while it isn't searched")
+	}
+	res, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'k'}, Alt: true})
+	m = res.(model)
+	if len(m.filtered) != 1 || m.hitCount(m.filtered[0]) != 1 {
+		t.Fatalf("alt+k should search thinking (%d results)", len(m.filtered))
+	}
+}
+
+func TestCollapsedThinkingLeavesPreviewSlotsToDialogue(t *testing.T) {
+	msg := func(role, kind, text string) Message { return Message{Role: role, Kind: kind, Text: text} }
+	conv := Conversation{SessionID: "s1", Messages: []Message{
+		msg("user", "", "first question"),
+		msg("assistant", kindThinking, "pondering"),
+		msg("assistant", kindThinking, "pondering more"),
+		msg("assistant", "", "first answer"),
+		msg("user", "", "middle question"),
+		msg("assistant", "", "middle answer"),
+		msg("user", "", "last question"),
+		msg("assistant", kindThinking, "pondering"),
+		msg("assistant", "", "last answer"),
+		msg("assistant", kindThinking, "afterthought"),
+	}}
+	preview := strings.Join(buildPreviewLines(conv, parseQuery(""), previewOpts{}), "\n")
+	for _, text := range []string{"first question", "first answer", "last question", "last answer"} {
+		if !strings.Contains(preview, text) {
+			t.Errorf("preview should show %q:\n%s", text, preview)
+		}
+	}
+	if strings.Contains(preview, "middle") || strings.Count(preview, "Alt+E to expand") != 4 {
+		t.Errorf("the middle should be skipped and the thinking around shown messages collapsed:\n%s", preview)
+	}
+}
+
 func TestGetTopicFallsBackToFirstMessage(t *testing.T) {
 	conv := Conversation{Messages: []Message{{Role: "user", Text: "first msg"}}}
 	if got := getTopic(conv); got != "first msg" {
@@ -727,12 +1040,26 @@ func TestUpdateFilter(t *testing.T) {
 		t.Errorf("'hello' query should return 2 items, got %d", len(m.filtered))
 	}
 
+	// Test: smart case - uppercase in the query matches case
+	m.textInput.SetValue("WORLD")
+	m.updateFilter()
+	if len(m.filtered) != 0 {
+		t.Errorf("'WORLD' query should return 0 items (smart case), got %d", len(m.filtered))
+	}
+	m.textInput.SetValue("World")
+	m.updateFilter()
+	if len(m.filtered) != 2 {
+		t.Errorf("'World' query should return 2 items (smart case), got %d", len(m.filtered))
+	}
+
 	// Test: case insensitive
+	m.opts.caseMode = caseIgnore
 	m.textInput.SetValue("WORLD")
 	m.updateFilter()
 	if len(m.filtered) != 2 {
 		t.Errorf("'WORLD' query should return 2 items (case insensitive), got %d", len(m.filtered))
 	}
+	m.opts.caseMode = caseSmart
 
 	// Test: no matches
 	m.textInput.SetValue("xyz")
@@ -750,120 +1077,1053 @@ func TestUpdateFilter(t *testing.T) {
 	}
 }
 
-func TestFormatListItem(t *testing.T) {
+func TestParseQueryQualifiers(t *testing.T) {
 	conv := Conversation{
-		SessionID:     "test-123",
-		Cwd:           "/home/user/very-long-project-name-that-exceeds-column-width",
-		LastTimestamp: "2024-01-15T10:30:00Z",
+		SessionID:      "3f2a-session",
+		Title:          "Auth refactor",
+		Cwd:            "/home/user/api",
+		FirstTimestamp: "2026-01-10T10:00:00Z",
+		LastTimestamp:  "2026-02-10T10:00:00Z",
+		Size:           60 << 20,
 		Messages: []Message{
-			{Role: "user", Text: "This is a very long first message that should be truncated to fit in the column"},
-			{Role: "assistant", Text: "Response"},
-			{Role: "user", Text: "Second message"},
+			{Role: "user", Text: "run the migration"},
+			{Role: "assistant", Text: "postgres is ready"},
 		},
 	}
+	item := buildItems([]Conversation{conv})[0]
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"project:api", true},
+		{"project:web", false},
+		{"title:auth", true},
+		{"session:3F2A", true},
+		{"session:2a", false},
+		{"role:user migration", true},
+		{"role:user postgres", false},
+		{"role:assistant postgres", true},
+		{"after:2026-02-01", true},
+		{"after:2026-03", false},
+		{"before:2026-01-11", true},
+		{"before:2026", false},
+		{"size:>50MB", true},
+		{"size:<1.5m", false},
+		{"msgs:2", true},
+		{"msgs:>=3", false},
+		{"project:api migration", true},
+		{"project:api nothing-here", false},
+		{"todo: migration", false}, // unknown key is free text
+		{"project:", true},         // no value yet: ignored
+	}
+	for _, tt := range tests {
+		q := parseQuery(tt.query)
+		if q.err != nil {
+			t.Errorf("parseQuery(%q) error: %v", tt.query, q.err)
+			continue
+		}
+		if got := q.matchItem(item); got != tt.want {
+			t.Errorf("parseQuery(%q).matchItem = %v, want %v", tt.query, got, tt.want)
+		}
+	}
 
-	item := listItem{conv: conv, searchText: ""}
-	m := initialModel([]listItem{item}, "", nil)
+	for _, bad := range []string{"role:bot", "after:yesterday", "size:>lots", "msgs:>-1"} {
+		if q := parseQuery(bad); q.err == nil {
+			t.Errorf("parseQuery(%q) should report an error", bad)
+		}
+	}
+}
 
-	// Test selected formatting
-	result := m.formatListItem(item, true)
-	if !strings.Contains(result, "2024-01-15 10:30") {
-		t.Errorf("formatted item should contain timestamp")
+func TestParseQueryBooleanTerms(t *testing.T) {
+	item := buildItems([]Conversation{{
+		SessionID: "s1",
+		Cwd:       "/home/user/api",
+		Messages: []Message{
+			{Role: "user", Text: "plan the migration"},
+			{Role: "assistant", Text: "postgres needs a new index"},
+		},
+	}})[0]
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"migration postgres", true},          // ANDed across messages
+		{"migration mysql", false},            // one term missing
+		{"mysql OR postgres", true},           // alternatives
+		{"mysql OR sqlite", false},            // no alternative present
+		{"migration mysql OR postgres", true}, // OR binds tighter than AND
+		{`"new index"`, true},                 // contiguous phrase
+		{`"index new"`, false},                // phrase order matters
+		{"migration -mysql", true},            // exclusion absent
+		{"migration -postgres", false},        // exclusion present
+		{`-"new index"`, false},               // negated phrase
+		{"-project:api", false},               // negated qualifier
+		{"-project:web migration", true},
+		{`project:"api" "plan the"`, true}, // quoted qualifier value
 	}
-	if !strings.Contains(result, "3") { // message count
-		t.Errorf("formatted item should contain message count")
+	for _, tt := range tests {
+		q := parseQuery(tt.query)
+		if q.err != nil {
+			t.Errorf("parseQuery(%q) error: %v", tt.query, q.err)
+			continue
+		}
+		if got := q.matchItem(item); got != tt.want {
+			t.Errorf("parseQuery(%q).matchItem = %v, want %v", tt.query, got, tt.want)
+		}
 	}
 
-	// Test non-selected formatting (has ANSI codes)
-	result = m.formatListItem(item, false)
-	if !strings.Contains(result, "\033[") {
-		t.Errorf("non-selected item should contain ANSI color codes")
+	// The list, HITS and preview agree: each message matching any positive term
+	// is a hit, and every positive term is highlighted.
+	conv := item.conv
+	query := "migration OR postgres -mysql"
+	if got := countHits(conv, parseQuery(query)); got != 2 {
+		t.Errorf("countHits(%q) = %d, want 2", query, got)
 	}
-
-	// Test hit count with query
-	m.textInput.SetValue("message")
-	result = m.formatListItem(item, false)
-	// Should show 2 hits (both user messages contain "message")
-	if !strings.Contains(result, "2") {
-		t.Errorf("should show hit count when query matches")
+	if got := strings.Count(strings.Join(buildPreviewLines(conv, parseQuery(query), previewOpts{}), "\n"), ">>>"); got != 2 {
+		t.Errorf("preview should mark 2 matching messages, got %d", got)
+	}
+	got := highlight("postgres migration mysql", query)
+	if strings.Count(got, "\033[43;30m") != 2 || strings.Contains(got, "\033[43;30mmysql") {
+		t.Errorf("highlight should mark both positive terms and not the exclusion: %q", got)
 	}
 }
 
-func TestFormatListItemNamedSessionMarker(t *testing.T) {
-	custom := listItem{conv: Conversation{
-		SessionID:     "s1",
-		Title:         "Refactor auth flow",
-		IsCustomTitle: true,
-		LastTimestamp: "2024-01-15T10:30:00Z",
-		Messages:      []Message{{Role: "user", Text: "hi"}},
-	}}
-	// ai-title: has a Title but it's auto-generated - must NOT get the marker.
-	aiTitled := listItem{conv: Conversation{
-		SessionID:     "s2",
-		Title:         "Some auto generated title",
-		IsCustomTitle: false,
-		LastTimestamp: "2024-01-15T10:30:00Z",
-		Messages:      []Message{{Role: "user", Text: "hi"}},
-	}}
-	fallback := listItem{conv: Conversation{
-		SessionID:     "s3",
-		LastTimestamp: "2024-01-15T10:30:00Z",
-		Messages:      []Message{{Role: "user", Text: "just a first message"}},
+func TestSmartCaseAndCaseModes(t *testing.T) {
+	conv := Conversation{SessionID: "s1", Messages: []Message{
+		{Role: "user", Text: "where is the Config loaded, I decided"},
+		{Role: "assistant", Text: "the config file sets the user ID"},
 	}}
-	m := initialModel([]listItem{custom, aiTitled, fallback}, "", nil)
-	m.width = 120 // give TOPIC room so the title isn't truncated
+	item := buildItems([]Conversation{conv})[0]
+	tests := []struct {
+		query string
+		mode  caseMode
+		hits  int
+	}{
+		{"config", caseSmart, 2},      // lowercase: ignores case
+		{"Config", caseSmart, 1},      // uppercase: matches case
+		{"ID", caseSmart, 1},          // acronym only, not "decided"
+		{"id", caseSmart, 2},          // both
+		{"config", caseSensitive, 1},  // explicit: lowercase matches case too
+		{"CONFIG", caseIgnore, 2},     // explicit: uppercase ignores case
+		{`/\bC\w+/`, caseSmart, 1},    // regex with uppercase matches case
+		{`/\w+\s+id$/`, caseSmart, 1}, // \s is an escape, not uppercase
+	}
+	for _, tt := range tests {
+		q := parseQueryOpts(tt.query, searchOpts{caseMode: tt.mode})
+		if got := countHits(conv, q); got != tt.hits {
+			t.Errorf("%q (mode %d): hits = %d, want %d", tt.query, tt.mode, got, tt.hits)
+		}
+		if got := q.matchItem(item); got != (tt.hits > 0) {
+			t.Errorf("%q (mode %d): matchItem = %v", tt.query, tt.mode, got)
+		}
+	}
 
-	if got := m.formatListItem(custom, false); !strings.Contains(got, "✎ Refactor auth flow") {
-		t.Errorf("user-set custom title should show the marker, got %q", got)
+	q := parseQuery("Config")
+	if got := q.highlight("config Config"); got != "config \033[43;30mConfig\033[0m" {
+		t.Errorf("case-sensitive highlight = %q", got)
 	}
-	if got := m.formatListItem(aiTitled, false); strings.Contains(got, "✎") {
-		t.Errorf("auto ai-title must NOT show the marker, got %q", got)
+	if got := parseQueryOpts("Config", searchOpts{fuzzy: true}).highlight("config Config"); got != "config \033[43;30mConfig\033[0m" {
+		t.Errorf("case-sensitive fuzzy highlight = %q", got)
 	}
-	if got := m.formatListItem(fallback, false); strings.Contains(got, "✎") {
-		t.Errorf("first-message fallback should not show the marker, got %q", got)
+
+	m := initialModel([]listItem{item}, "CONFIG", nil)
+	m.width, m.height = 120, 30
+	if len(m.filtered) != 0 {
+		t.Fatal("smart case: CONFIG should not match")
+	}
+	for _, want := range []string{"case", "nocase"} {
+		res, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'c'}, Alt: true})
+		m = res.(model)
+		if !strings.Contains(m.View(), want) {
+			t.Errorf("alt+c should show %q mode", want)
+		}
+	}
+	if m.opts.caseMode != caseIgnore || len(m.filtered) != 1 {
+		t.Errorf("alt+c twice should ignore case (mode %d, %d results)", m.opts.caseMode, len(m.filtered))
 	}
 }
 
-func TestTopicColWidthFlexes(t *testing.T) {
-	fixed := listIndent + colDate + colProject + colMsgs + colHits + colSize + numGaps*colGap
-	for _, w := range []int{80, 120, 200} {
-		m := model{width: w}
-		if got, want := m.topicColWidth(), w-fixed; got != want {
-			t.Errorf("topicColWidth(width=%d) = %d, want %d", w, got, want)
-		}
+func TestCasedMatchIgnoresUnicodeNormalization(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "nfd.jsonl")
+	content := `{"type":"user","cwd":"/test","sessionId":"nfd","message":{"content":"the Cafe\u0301 menu"},"timestamp":"2024-01-15T10:00:00Z"}
+`
+	if err := os.WriteFile(testFile, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
 	}
-	// Tiny terminal clamps to a minimum rather than going negative.
-	if got := (model{width: 20}).topicColWidth(); got != 10 {
-		t.Errorf("topicColWidth(width=20) = %d, want 10 (min)", got)
+	conv, err := parseConversationFile(testFile, time.Time{}, 0)
+	if err != nil || conv == nil {
+		t.Fatalf("parseConversationFile failed: %v", err)
+	}
+	item := buildItems([]Conversation{*conv})[0]
+	// Precomposed é against decomposed text, and the other way round.
+	for _, query := range []string{"Café", "Cafe\u0301", "/Café/"} {
+		for _, mode := range []caseMode{caseSmart, caseSensitive} {
+			q := parseQueryOpts(query, searchOpts{caseMode: mode})
+			if !q.matchItem(item) || countHits(*conv, q) != 1 {
+				t.Errorf("%q (mode %d) should match decomposed text", query, mode)
+			}
+			if got := q.highlight(conv.Messages[0].Text); !strings.Contains(got, "\033[43;30mCafé\033[0m") {
+				t.Errorf("%q (mode %d): highlight = %q", query, mode, got)
+			}
+		}
 	}
 }
 
-func TestFormatListItemFillsWidth(t *testing.T) {
-	item := listItem{conv: Conversation{
-		SessionID:     "s",
-		LastTimestamp: "2024-01-15T10:30:00Z",
-		Size:          1 << 20,
-		Messages:      []Message{{Role: "user", Text: "hi"}},
-	}}
-	for _, w := range []int{80, 120, 200} {
-		m := initialModel([]listItem{item}, "", nil)
-		m.width = w
-		// Selected row has no ANSI codes; its width + the 2-char row prefix
-		// added by View should fill the terminal exactly.
-		if got := len(m.formatListItem(item, true)); got != w-listIndent {
-			t.Errorf("width=%d: row length = %d, want %d", w, got, w-listIndent)
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		text, pattern string
+		want          bool
+	}{
+		{"kubectl apply", "kubctl", true},
+		{"kubectl apply", "kctl", true},
+		{"kubectl apply", "ctlkub", false},                         // out of order
+		{"k" + strings.Repeat("x", 40) + "ubctl", "kubctl", false}, // spread too far
+		{"k" + strings.Repeat("x", 40) + "ubctl kubectl", "kubctl", true},
+		{"kubectl apply", "", false},
+	}
+	for _, tt := range tests {
+		pos, _ := fuzzyMatch([]rune(tt.text), []rune(tt.pattern))
+		if got := pos != nil; got != tt.want {
+			t.Errorf("fuzzyMatch(%q, %q) matched = %v, want %v", tt.text, tt.pattern, got, tt.want)
 		}
 	}
+	// Consecutive characters at a word start outscore a scattered match.
+	_, tight := fuzzyMatch([]rune("run kubectl"), []rune("kube"))
+	_, loose := fuzzyMatch([]rune("kind/ubuntu/base"), []rune("kube"))
+	if tight <= loose {
+		t.Errorf("tight score %d should beat scattered score %d", tight, loose)
+	}
+	// The first window narrow enough wins, shrunk to its tightest form.
+	if pos, _ := fuzzyMatch([]rune("k xx kubxctl"), []rune("kubctl")); !slices.Equal(pos, []int{5, 6, 7, 9, 10, 11}) {
+		t.Errorf("fuzzyMatch positions = %v", pos)
+	}
 }
 
-func TestUpdateKeyboardNavigation(t *testing.T) {
-	items := []listItem{
-		{conv: Conversation{SessionID: "test-1"}, searchText: "first"},
-		{conv: Conversation{SessionID: "test-2"}, searchText: "second"},
-		{conv: Conversation{SessionID: "test-3"}, searchText: "third"},
+// BenchmarkFuzzyMatch runs the worst case for a restart-at-each-start scan: a
+// long text full of the pattern's first rune, each occurrence too far from the
+// last.
+func BenchmarkFuzzyMatch(b *testing.B) {
+	text := []rune(strings.Repeat("k"+strings.Repeat("x", 30), 30000) + "ubctl")
+	pattern := []rune("kubctl")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if pos, _ := fuzzyMatch(text, pattern); pos != nil {
+			b.Fatal("unexpected match")
+		}
 	}
+}
 
-	m := initialModel(items, "", nil)
+// BenchmarkFuzzyFilter is one keystroke of fuzzy search - filter and rank -
+// over 500 conversations of about 10KB of dialogue each, half of them
+// non-ASCII.
+func BenchmarkFuzzyFilter(b *testing.B) {
+	var convs []Conversation
+	for i := range 500 {
+		word := "deploy the service"
+		if i%2 == 1 {
+			word = "déployer le café"
+		}
+		var msgs []Message
+		for j := range 50 {
+			msgs = append(msgs, Message{Role: []string{"user", "assistant"}[j%2], Text: strings.Repeat(word+" and kubectl logs ", 10)})
+		}
+		convs = append(convs, Conversation{SessionID: fmt.Sprintf("s%d", i), Messages: msgs})
+	}
+	m := initialModel(buildItems(convs), "", nil)
+	m.opts.fuzzy = true
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.textInput.SetValue([]string{"kbctl", "dply"}[i%2])
+		m.lastQuery = nil
+		m.updateFilter()
+		if len(m.filtered) == 0 {
+			b.Fatal("no matches")
+		}
+	}
+}
+
+func TestFuzzyModeFiltersRanksAndHighlights(t *testing.T) {
+	items := buildItems([]Conversation{
+		{SessionID: "scattered", LastTimestamp: "2024-01-17T10:00:00Z",
+			Messages: []Message{{Role: "user", Text: "keep unused bits elsewhere"}}},
+		{SessionID: "titled", Title: "kubectl rollout", LastTimestamp: "2024-01-16T10:00:00Z",
+			Messages: []Message{{Role: "user", Text: "hello"}}},
+		{SessionID: "body", LastTimestamp: "2024-01-15T10:00:00Z",
+			Messages: []Message{{Role: "user", Text: "run kubectl apply"}}},
+		{SessionID: "none", LastTimestamp: "2024-01-14T10:00:00Z",
+			Messages: []Message{{Role: "user", Text: "nothing relevant"}}},
+	})
+	m := initialModel(items, "kubctl", nil)
+	if len(m.filtered) != 0 {
+		t.Fatalf("exact mode should not match the typo, got %d", len(m.filtered))
+	}
+
+	res, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'z'}, Alt: true})
+	m = res.(model)
+	if !m.opts.fuzzy {
+		t.Fatal("alt+z should enable fuzzy mode")
+	}
+	var ids []string
+	for _, item := range m.filtered {
+		ids = append(ids, item.conv.SessionID)
+	}
+	// The title hit is boosted above the message hit despite being older.
+	if strings.Join(ids, ",") != "titled,body" {
+		t.Errorf("fuzzy results = %v, want [titled body]", ids)
+	}
+	if got := m.hitCount(m.filtered[1]); got != 1 {
+		t.Errorf("fuzzy hit count = %d, want 1", got)
+	}
+
+	// Matched characters are highlighted individually: k-u-b then c-t-l.
+	got := m.currentQuery().highlight("kubectl")
+	if n := strings.Count(got, "\033[43;30m"); n != 2 {
+		t.Errorf("expected 2 highlighted runs in %q, got %d", got, n)
+	}
+
+	res, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'z'}, Alt: true})
+	m = res.(model)
+	if m.opts.fuzzy || len(m.filtered) != 0 {
+		t.Errorf("second alt+z should restore exact matching (fuzzy=%v, %d results)", m.opts.fuzzy, len(m.filtered))
+	}
+}
+
+func TestRegexTerms(t *testing.T) {
+	items := buildItems([]Conversation{
+		{SessionID: "s1", Messages: []Message{
+			{Role: "user", Text: "it crashed"},
+			{Role: "assistant", Text: "Panic: assignment to entry in nil map"},
+		}},
+		{SessionID: "s2", Messages: []Message{{Role: "user", Text: "order 4f2a-19 failed"}}},
+	})
+	tests := []struct {
+		query string
+		want  []bool
+	}{
+		{"/panic: .*nil map/", []bool{true, false}}, // spaces inside, case-insensitive
+		{`/\d+[a-f]-\d+/`, []bool{false, true}},
+		{"-/nil map/ failed", []bool{false, true}},
+		{"/usr/bin", []bool{false, false}}, // not a regex: no closing slash at a word end
+	}
+	for _, tt := range tests {
+		q := parseQuery(tt.query)
+		if q.err != nil {
+			t.Errorf("parseQuery(%q) error: %v", tt.query, q.err)
+			continue
+		}
+		for i, item := range items {
+			if got := q.matchItem(item); got != tt.want[i] {
+				t.Errorf("parseQuery(%q).matchItem(%s) = %v, want %v", tt.query, item.conv.SessionID, got, tt.want[i])
+			}
+		}
+	}
+
+	q := parseQuery("/nil m.p/")
+	if got := countHits(items[0].conv, q); got != 1 {
+		t.Errorf("regex hit count = %d, want 1", got)
+	}
+	if got := q.highlight("in nil map"); got != "in \033[43;30mnil map\033[0m" {
+		t.Errorf("regex highlight = %q", got)
+	}
+	if got := parseQuery("/ü+/").highlight("grüüße"); got != "gr\033[43;30müü\033[0mße" {
+		t.Errorf("regex highlight should land on runes, got %q", got)
+	}
+
+	if q := parseQuery("/x(/"); q.err == nil || !strings.Contains(q.err.Error(), "missing closing )") {
+		t.Errorf("invalid regex should be reported, got %v", q.err)
+	}
+}
+
+func TestRegexModeToggle(t *testing.T) {
+	items := buildItems([]Conversation{
+		{SessionID: "a", Messages: []Message{{Role: "user", Text: "error E1234 raised"}}},
+		{SessionID: "b", Messages: []Message{{Role: "user", Text: "E.. is literal here"}}},
+	})
+	m := initialModel(items, "E..", nil)
+	m.width, m.height = 120, 30
+	if len(m.filtered) != 1 || m.filtered[0].conv.SessionID != "b" {
+		t.Fatalf("plain mode should match the literal text, got %d results", len(m.filtered))
+	}
+	res, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'r'}, Alt: true})
+	m = res.(model)
+	if !m.opts.regex || len(m.filtered) != 2 {
+		t.Fatalf("alt+r should switch to regex matching (regex=%v, %d results)", m.opts.regex, len(m.filtered))
+	}
+	if !strings.Contains(m.View(), "regex") {
+		t.Error("search line should show regex mode")
+	}
+
+	// An invalid pattern keeps the last results and shows the error.
+	m.textInput.SetValue("E[")
+	m.updateFilter()
+	if len(m.filtered) != 2 || !strings.Contains(m.View(), "missing closing ]") {
+		t.Errorf("invalid regex should keep %d results and report the error", len(m.filtered))
+	}
+}
+
+func TestRelevanceSortRanksByTermFrequency(t *testing.T) {
+	items := buildItems([]Conversation{
+		{SessionID: "once", LastTimestamp: "2024-01-17T10:00:00Z",
+			Messages: []Message{{Role: "user", Text: "postgres came up once"}}},
+		{SessionID: "depth", LastTimestamp: "2024-01-15T10:00:00Z",
+			Messages: []Message{
+				{Role: "user", Text: "postgres migration failing"},
+				{Role: "assistant", Text: "check the postgres logs, postgres is strict"},
+			}},
+		{SessionID: "other", LastTimestamp: "2024-01-16T10:00:00Z",
+			Messages: []Message{{Role: "user", Text: "unrelated"}}},
+	})
+	m := initialModel(items, "postgres", nil)
+	m.width, m.height = 140, 30
+	if m.filtered[0].conv.SessionID != "once" {
+		t.Fatalf("default sort should be most recent first, got %s", m.filtered[0].conv.SessionID)
+	}
+	m.cursor = 1 // "depth"
+
+	res, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+	m = res.(model)
+	if m.sortBy != sortRelevance {
+		t.Fatal("ctrl+s should sort by relevance")
+	}
+	if m.filtered[0].conv.SessionID != "depth" {
+		t.Errorf("the conversation about postgres should rank first, got %s", m.filtered[0].conv.SessionID)
+	}
+	if m.selectedID() != "depth" {
+		t.Errorf("re-sorting should keep the selection, got %s", m.selectedID())
+	}
+	if !(m.filtered[0].score > m.filtered[1].score && m.filtered[1].score > 0) {
+		t.Errorf("scores should be positive and descending: %v, %v", m.filtered[0].score, m.filtered[1].score)
+	}
+	if !strings.Contains(m.View(), "SCORE") {
+		t.Error("relevance sort should show a SCORE column")
+	}
+
+	res, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+	m = res.(model)
+	if m.sortBy == sortRelevance || strings.Contains(m.View(), "SCORE") {
+		t.Error("second ctrl+s should move on from relevance and drop the SCORE column")
+	}
+}
+
+func TestSortOrdersCycleAndReverse(t *testing.T) {
+	items := buildItems([]Conversation{
+		{SessionID: "new", Title: "beta", Size: 10, FirstTimestamp: "2024-01-01T10:00:00Z", LastTimestamp: "2024-01-20T10:00:00Z",
+			Messages: []Message{{Role: "user", Text: "x"}}},
+		{SessionID: "mid", Title: "Alpha", Size: 30, FirstTimestamp: "2024-01-14T10:00:00Z", LastTimestamp: "2024-01-15T10:00:00Z",
+			Messages: []Message{{Role: "user", Text: "x"}, {Role: "assistant", Text: "x x"}, {Role: "user", Text: "y"}}},
+		{SessionID: "old", Title: "gamma", Size: 20, FirstTimestamp: "2024-01-09T10:00:00Z", LastTimestamp: "2024-01-10T10:00:00Z",
+			Messages: []Message{{Role: "user", Text: "x"}, {Role: "assistant", Text: "x"}}},
+	})
+	m := initialModel(items, "", nil)
+	m.width, m.height = 140, 30
+	order := func() string {
+		var ids []string
+		for _, item := range m.filtered {
+			ids = append(ids, item.conv.SessionID)
+		}
+		return strings.Join(ids, " ")
+	}
+	press := func(msg tea.KeyMsg) {
+		res, _ := m.Update(msg)
+		m = res.(model)
+	}
+	ctrlS := tea.KeyMsg{Type: tea.KeyCtrlS}
+	altS := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'s'}, Alt: true}
+
+	m.cursor = 2 // "old"
+	// Without a search, relevance and hits are skipped.
+	for _, want := range []struct {
+		by     sortOrder
+		order  string
+		header string
+	}{
+		{sortStarted, "mid old new", "STARTED↓"},
+		{sortSize, "mid old new", "SIZE↓"},
+		{sortMsgs, "mid old new", "MSGS↓"},
+		{sortTitle, "mid new old", "TOPIC↑"},
+		{sortRecent, "new mid old", "DATE↓"},
+	} {
+		press(ctrlS)
+		if m.sortBy != want.by || order() != want.order {
+			t.Errorf("sort %d: got %d with %q, want %q", want.by, m.sortBy, order(), want.order)
+		}
+		if !strings.Contains(m.View(), want.header) {
+			t.Errorf("sort %d: header should show %q", want.by, want.header)
+		}
+		if m.selectedID() != "old" {
+			t.Errorf("sort %d: selection moved to %s", want.by, m.selectedID())
+		}
+	}
+
+	press(ctrlS) // started
+	press(altS)
+	if order() != "new old mid" || !strings.Contains(m.View(), "STARTED↑") {
+		t.Errorf("alt+s should reverse to earliest first, got %q", order())
+	}
+
+	// While searching, hits can be sorted by; ties stay newest first.
+	m.textInput.SetValue("x")
+	m.updateFilter()
+	m.sortBy, m.sortReverse = sortMsgs, false
+	press(ctrlS)
+	if m.sortBy != sortHits || order() != "mid old new" || !strings.Contains(m.View(), "HITS↓") {
+		t.Errorf("hits sort: got %d with %q", m.sortBy, order())
+	}
+}
+
+func TestListHeaderLinesUpWithRowsUnderEverySort(t *testing.T) {
+	items := buildItems([]Conversation{{
+		SessionID: "s1", Title: "needle hunt", Cwd: "/home/user/api", Size: 123456,
+		LastTimestamp: "2024-01-15T10:00:00Z",
+		Messages:      []Message{{Role: "user", Text: "the needle"}},
+	}})
+	for by := range numSortOrders {
+		for _, reverse := range []bool{false, true} {
+			m := initialModel(items, "needle", nil)
+			m.width, m.height, m.layout = 160, 30, layoutStacked
+			m.sortBy, m.sortReverse, m.showBranch = by, reverse, true
+			m.updateFilter()
+			lines := strings.Split(m.View(), "\n")
+			i := slices.IndexFunc(lines, func(line string) bool { return strings.Contains(line, "PROJECT") })
+			if i < 0 || i+2 >= len(lines) {
+				t.Fatalf("sort %d: no list header in:\n%s", by, m.View())
+			}
+			header, row := lines[i], lines[i+2]
+			if hw, rw := ansiWidth(header), ansiWidth(row); hw != rw {
+				t.Errorf("sort %d (reverse %v): header is %d cells, row %d:\n%s\n%s", by, reverse, hw, rw, header, row)
+			}
+		}
+	}
+}
+
+func TestBM25WeighsRareTermsAndTitles(t *testing.T) {
+	items := buildItems([]Conversation{
+		{SessionID: "a", Title: "deploy pipeline", Messages: []Message{{Role: "user", Text: "the deploy broke"}}},
+		{SessionID: "b", Messages: []Message{{Role: "user", Text: "the deploy broke again"}}},
+		{SessionID: "c", Messages: []Message{{Role: "user", Text: "the weather"}}},
+	})
+	bm := newBM25(parseQuery("deploy"), items)
+	if a, b := bm.score(items[0]), bm.score(items[1]); a <= b {
+		t.Errorf("a title hit should outrank a message hit: %v <= %v", a, b)
+	}
+	if got := bm.score(items[2]); got != 0 {
+		t.Errorf("non-matching conversation scored %v", got)
+	}
+	// "the" is in every conversation, so it adds far less than "deploy".
+	common := newBM25(parseQuery("the"), items).score(items[1])
+	rare := bm.score(items[1])
+	if common >= rare {
+		t.Errorf("common term should weigh less than a rare one: %v >= %v", common, rare)
+	}
+}
+
+func TestUpdateFilterInvalidQualifierKeepsResults(t *testing.T) {
+	items := buildItems([]Conversation{
+		{SessionID: "a", Cwd: "/api", Messages: []Message{{Role: "user", Text: "one"}}},
+		{SessionID: "b", Cwd: "/web", Messages: []Message{{Role: "user", Text: "two"}}},
+	})
+	m := initialModel(items, "project:api", nil)
+	m.width, m.height = 120, 30
+	if len(m.filtered) != 1 {
+		t.Fatalf("project:api should match 1, got %d", len(m.filtered))
+	}
+	m.textInput.SetValue("project:api size:>huge")
+	m.updateFilter()
+	if len(m.filtered) != 1 {
+		t.Errorf("invalid query should keep the previous results, got %d", len(m.filtered))
+	}
+	if !strings.Contains(m.View(), "size:>huge") {
+		t.Error("view should report the invalid qualifier")
+	}
+	// Hits and highlighting ignore qualifiers and use the free text only.
+	if got := countHits(items[0].conv, parseQuery("project:api one")); got != 1 {
+		t.Errorf("countHits with qualifier = %d, want 1", got)
+	}
+}
+
+func TestUnknownQualifierIsReported(t *testing.T) {
+	tests := []struct {
+		query string
+		err   string // "" for none
+	}{
+		{"projct:foo", "projct:foo - unknown qualifier (did you mean project:?"},
+		{"sesion:abc", "did you mean session:?"},
+		{"-Titel:x", "did you mean title:?"},
+		{"zzzzzz:1", "zzzzzz:1 - unknown qualifier (quote it"},
+		{`"TODO:fix"`, ""},          // quoted: text
+		{"TODO:", ""},               // nothing after the colon yet
+		{"https://example.com", ""}, // a URL
+		{"10:30", ""},               // not a word
+		{"project:api", ""},
+	}
+	for _, tt := range tests {
+		err := parseQuery(tt.query).err
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("parseQuery(%q).err = %v, want %q", tt.query, err, tt.err)
+		}
+	}
+}
+
+func TestFormatListItem(t *testing.T) {
+	conv := Conversation{
+		SessionID:     "test-123",
+		Cwd:           "/home/user/very-long-project-name-that-exceeds-column-width",
+		LastTimestamp: "2024-01-15T10:30:00Z",
+		Messages: []Message{
+			{Role: "user", Text: "This is a very long first message that should be truncated to fit in the column"},
+			{Role: "assistant", Text: "Response"},
+			{Role: "user", Text: "Second message"},
+		},
+	}
+
+	item := listItem{conv: conv, searchText: ""}
+	m := initialModel([]listItem{item}, "", nil)
+
+	// Test selected formatting
+	result := m.formatListItem(item, true)
+	if !strings.Contains(result, "2024-01-15 10:30") {
+		t.Errorf("formatted item should contain timestamp")
+	}
+	if !strings.Contains(result, "3") { // message count
+		t.Errorf("formatted item should contain message count")
+	}
+
+	// Test non-selected formatting (has ANSI codes)
+	result = m.formatListItem(item, false)
+	if !strings.Contains(result, "\033[") {
+		t.Errorf("non-selected item should contain ANSI color codes")
+	}
+
+	// Test hit count with query
+	m.textInput.SetValue("message")
+	result = m.formatListItem(item, false)
+	// Should show 2 hits (both user messages contain "message")
+	if !strings.Contains(result, "2") {
+		t.Errorf("should show hit count when query matches")
+	}
+}
+
+func TestFormatListItemNamedSessionMarker(t *testing.T) {
+	custom := listItem{conv: Conversation{
+		SessionID:     "s1",
+		Title:         "Refactor auth flow",
+		IsCustomTitle: true,
+		LastTimestamp: "2024-01-15T10:30:00Z",
+		Messages:      []Message{{Role: "user", Text: "hi"}},
+	}}
+	// ai-title: has a Title but it's auto-generated - must NOT get the marker.
+	aiTitled := listItem{conv: Conversation{
+		SessionID:     "s2",
+		Title:         "Some auto generated title",
+		IsCustomTitle: false,
+		LastTimestamp: "2024-01-15T10:30:00Z",
+		Messages:      []Message{{Role: "user", Text: "hi"}},
+	}}
+	fallback := listItem{conv: Conversation{
+		SessionID:     "s3",
+		LastTimestamp: "2024-01-15T10:30:00Z",
+		Messages:      []Message{{Role: "user", Text: "just a first message"}},
+	}}
+	m := initialModel([]listItem{custom, aiTitled, fallback}, "", nil)
+	m.width = 120 // give TOPIC room so the title isn't truncated
+
+	if got := m.formatListItem(custom, false); !strings.Contains(got, "✎ Refactor auth flow") {
+		t.Errorf("user-set custom title should show the marker, got %q", got)
+	}
+	if got := m.formatListItem(aiTitled, false); strings.Contains(got, "✎") {
+		t.Errorf("auto ai-title must NOT show the marker, got %q", got)
+	}
+	if got := m.formatListItem(fallback, false); strings.Contains(got, "✎") {
+		t.Errorf("first-message fallback should not show the marker, got %q", got)
+	}
+}
+
+func TestTopicColWidthFlexes(t *testing.T) {
+	fixed := listIndent + colDate + colProject + colMsgs + colHits + colSize + numGaps*colGap
+	for _, w := range []int{80, 120, 200} {
+		m := model{width: w} // 200 is side by side: TOPIC fills the list pane
+		if got, want := m.topicColWidth(), m.listWidth()-fixed; got != want {
+			t.Errorf("topicColWidth(width=%d) = %d, want %d", w, got, want)
+		}
+	}
+	// Tiny terminal clamps to a minimum rather than going negative.
+	if got := (model{width: 20}).topicColWidth(); got != 10 {
+		t.Errorf("topicColWidth(width=20) = %d, want 10 (min)", got)
+	}
+}
+
+func TestFormatListItemFillsWidth(t *testing.T) {
+	item := listItem{conv: Conversation{
+		SessionID:     "s",
+		LastTimestamp: "2024-01-15T10:30:00Z",
+		Size:          1 << 20,
+		Messages:      []Message{{Role: "user", Text: "hi"}},
+	}}
+	for _, w := range []int{80, 120, 200} {
+		m := initialModel([]listItem{item}, "", nil)
+		m.width = w
+		// Selected row has no ANSI codes; its width + the 2-char row prefix
+		// added by View should fill the list pane exactly.
+		if got, want := len(m.formatListItem(item, true)), m.listWidth()-listIndent; got != want {
+			t.Errorf("width=%d: row length = %d, want %d", w, got, want)
+		}
+	}
+}
+
+func TestSideBySideLayout(t *testing.T) {
+	var convs []Conversation
+	for i := 0; i < 5; i++ {
+		convs = append(convs, Conversation{
+			SessionID: fmt.Sprintf("s%d", i),
+			Cwd:       "/home/u/project",
+			Messages:  []Message{{Role: "user", Text: strings.Repeat("long preview text ", 30)}},
+		})
+	}
+	m := initialModel(buildItems(convs), "", nil)
+	resize := func(w, h int) {
+		res, _ := m.Update(tea.WindowSizeMsg{Width: w, Height: h})
+		m = res.(model)
+	}
+	key := func(r rune) {
+		res, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}, Alt: true})
+		m = res.(model)
+	}
+
+	resize(120, 30)
+	if m.sideBySide() || m.previewWidth() != 120 {
+		t.Fatalf("a 120-wide terminal should stack the panes, preview width %d", m.previewWidth())
+	}
+
+	resize(200, 30)
+	if !m.sideBySide() || m.listWidth() != 100 || m.previewWidth() != 99 {
+		t.Fatalf("a 200-wide terminal should split evenly, got list %d preview %d", m.listWidth(), m.previewWidth())
+	}
+	lines := strings.Split(m.View(), "\n")
+	if len(lines) != 30 {
+		t.Errorf("view should fill the 30-line terminal, got %d lines", len(lines))
+	}
+	for i, line := range lines {
+		if w := ansiWidth(line); w > 200 {
+			t.Errorf("line %d is %d cells, wider than the terminal: %q", i, w, line)
+		}
+	}
+	if !strings.Contains(lines[3], "│") || !strings.Contains(lines[3], "DATE") || !strings.Contains(lines[3], "Project:") {
+		t.Errorf("header row should hold the list header and the preview side by side: %q", lines[3])
+	}
+	for _, line := range m.previewLines() {
+		if ansiWidth(line) > m.previewWidth() {
+			t.Errorf("preview line wider than its pane: %q", line)
+		}
+	}
+
+	key('=')
+	if m.listWidth() != 110 || m.topicColWidth() != m.listWidth()-(listIndent+colDate+colProject+colMsgs+colHits+colSize+numGaps*colGap) {
+		t.Errorf("alt+= should grow the list pane and TOPIC with it, list %d topic %d", m.listWidth(), m.topicColWidth())
+	}
+	for range 20 {
+		key('-')
+	}
+	if m.listPercent() != listPctMin {
+		t.Errorf("shrinking should stop at %d%%, got %d%%", listPctMin, m.listPercent())
+	}
+
+	key('l') // auto -> stacked
+	if m.layout != layoutStacked || m.sideBySide() || m.listPercent() != listPctStacked {
+		t.Errorf("alt+l should switch to the stacked layout at its default split, got %v %d%%", m.layout, m.listPercent())
+	}
+	key('l') // -> side, even on a narrow terminal
+	resize(100, 30)
+	if !m.sideBySide() || m.listWidth() != 50 {
+		t.Errorf("the side layout should stay side by side when narrow, list width %d", m.listWidth())
+	}
+	for i, line := range strings.Split(m.View(), "\n") {
+		if w := ansiWidth(line); w > 100 {
+			t.Errorf("narrow side-by-side line %d is %d cells: %q", i, w, line)
+		}
+	}
+}
+
+func TestFacetSidebarNarrowsList(t *testing.T) {
+	conv := func(id, cwd, month string, branches ...string) Conversation {
+		return Conversation{SessionID: id, Cwd: cwd, Branches: branches, Models: []string{"claude-opus-4"},
+			LastTimestamp: month + "-15T12:00:00Z", Messages: []Message{{Role: "user", Text: "deploy " + id}}}
+	}
+	items := buildItems([]Conversation{
+		conv("a", "/src/api", "2026-02", "main"),
+		conv("b", "/src/api", "2026-01", "feature"),
+		conv("c", "/src/web", "2026-01", "main"),
+		conv("d", "/src/web", "2025-12", "main", "feature"),
+	})
+	m := initialModel(items, "", nil)
+	m.width, m.height = 140, 40
+	press := func(msg tea.KeyMsg) {
+		res, _ := m.Update(msg)
+		m = res.(model)
+	}
+	alt := func(r rune) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}, Alt: true} }
+	ids := func() string {
+		var out []string
+		for _, item := range m.filtered {
+			out = append(out, item.conv.SessionID)
+		}
+		return strings.Join(out, " ")
+	}
+	count := func(kind facetKind, value string) int {
+		for _, c := range m.facets.counts[kind] {
+			if c.value == value {
+				return c.n
+			}
+		}
+		return -1
+	}
+	// moveTo puts the sidebar cursor on a value.
+	moveTo := func(kind facetKind, value string) {
+		for i, r := range m.facets.rows() {
+			if r.kind == kind && r.value == value {
+				m.facets.cursor = i
+				return
+			}
+		}
+		t.Fatalf("no %s %q in the sidebar", facetNames[kind], value)
+	}
+
+	press(alt('g'))
+	if !m.facets.open || !m.facets.focused {
+		t.Fatal("alt+g should open and focus the sidebar")
+	}
+	if count(facetProject, "/src/api") != 2 || count(facetBranch, "main") != 3 || count(facetBranch, "feature") != 2 ||
+		count(facetModel, "claude-opus-4") != 4 || count(facetMonth, "2026-01") != 2 {
+		t.Errorf("counts over all conversations are wrong: %+v", m.facets.counts)
+	}
+	view := m.View()
+	if !strings.Contains(view, "BRANCH") || !strings.Contains(view, "[ ] api") {
+		t.Errorf("sidebar should list facets:\n%s", view)
+	}
+	for i, line := range strings.Split(view, "\n") {
+		if w := ansiWidth(line); w > m.width {
+			t.Errorf("line %d is %d cells, wider than the terminal: %q", i, w, line)
+		}
+	}
+
+	moveTo(facetBranch, "feature")
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	if ids() != "b d" {
+		t.Errorf("choosing branch feature should leave b and d, got %q", ids())
+	}
+	if count(facetBranch, "main") != 3 || count(facetProject, "/src/web") != 1 {
+		t.Error("a facet's counts should ignore its own choice but apply the others'")
+	}
+	moveTo(facetBranch, "main") // same facet: either branch
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	moveTo(facetProject, "/src/web") // another facet: both must hold
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	if ids() != "c d" {
+		t.Errorf("branch main or feature, in /src/web: got %q", ids())
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.facets.focused || m.quitting {
+		t.Fatal("esc should hand the keys back to the list without quitting")
+	}
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(`"deploy c"`)})
+	if ids() != "c" || count(facetMonth, "2026-01") != 1 {
+		t.Errorf("the query and the facets should combine, got %q", ids())
+	}
+
+	press(alt('g'))
+	press(alt('g'))
+	if m.facets.open || m.facets.active() || ids() != "c" {
+		t.Errorf("closing the sidebar should drop its choices, got %q", ids())
+	}
+}
+
+func TestFacetSidebarInNarrowTerminal(t *testing.T) {
+	items := buildItems([]Conversation{{SessionID: "s1", Cwd: "/src/api", Messages: []Message{{Role: "user", Text: "deploy"}}}})
+	for _, layout := range []layoutMode{layoutStacked, layoutSide} {
+		for _, width := range []int{1, 20, sidebarWidth, sidebarWidth + 1, 40} {
+			m := initialModel(items, "", nil)
+			m.layout = layout
+			res, _ := m.Update(tea.WindowSizeMsg{Width: width, Height: 20})
+			res, _ = res.(model).Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'g'}, Alt: true})
+			m = res.(model)
+			if !m.facets.open || m.listWidth() < 0 {
+				t.Errorf("width %d: sidebar open %v, list width %d", width, m.facets.open, m.listWidth())
+			}
+			_ = m.View() // must not panic
+		}
+	}
+}
+
+func TestHereScopesToCurrentRepository(t *testing.T) {
+	base := t.TempDir()
+	repo := filepath.Join(base, "repo")
+	sub := filepath.Join(repo, "cmd", "tool")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(repo, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if got := gitRoot(sub); got != repo {
+		t.Fatalf("gitRoot(%s) = %q, want %q", sub, got, repo)
+	}
+
+	items := buildItems([]Conversation{
+		{SessionID: "root", Cwd: repo, Messages: []Message{{Role: "user", Text: "x"}}},
+		{SessionID: "sub", Cwd: sub, Messages: []Message{{Role: "user", Text: "x"}}},
+		{SessionID: "sibling", Cwd: repo + "-old", Messages: []Message{{Role: "user", Text: "x"}}},
+		{SessionID: "elsewhere", Cwd: base, Messages: []Message{{Role: "user", Text: "x"}}},
+	})
+	m := initialModel(items, "", nil)
+	m.width, m.height = 120, 30
+	m.scope = newHereScope(sub) // started deep inside the repository
+	ids := func() string {
+		var out []string
+		for _, item := range m.filtered {
+			out = append(out, item.conv.SessionID)
+		}
+		return strings.Join(out, " ")
+	}
+
+	res, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'h'}, Alt: true})
+	m = res.(model)
+	if !m.here || ids() != "root sub" {
+		t.Errorf("alt+h should keep the repository's conversations, got %q", ids())
+	}
+	if !strings.Contains(m.View(), "here:repo") {
+		t.Error("the search line should show that the scope is active")
+	}
+	m.textInput.SetValue("x")
+	m.updateFilter()
+	if ids() != "root sub" {
+		t.Errorf("the scope should apply to searches too, got %q", ids())
+	}
+
+	res, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'h'}, Alt: true})
+	m = res.(model)
+	if m.here || len(m.filtered) != 4 || strings.Contains(m.View(), "here:") {
+		t.Errorf("alt+h again should list everything, got %q", ids())
+	}
+
+	// Outside a repository the scope is the directory itself.
+	if sc := newHereScope(base); !sc.contains(items[0].conv) || sc.contains(Conversation{Cwd: filepath.Dir(base)}) {
+		t.Errorf("scope of a plain directory should cover what is under it only: %+v", sc)
+	}
+}
+
+func TestWorktreesGroupUnderRepository(t *testing.T) {
+	// repo is a checkout with a linked worktree elsewhere, laid out as
+	// "git worktree add ../repo-feature" leaves it.
+	base := t.TempDir()
+	repo := filepath.Join(base, "repo")
+	wt := filepath.Join(base, "repo-feature")
+	wtGit := filepath.Join(repo, ".git", "worktrees", "repo-feature")
+	for _, dir := range []string{filepath.Join(repo, "src"), filepath.Join(wt, "src"), wtGit} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(wt, ".git"), []byte("gitdir: "+wtGit+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(wtGit, "commondir"), []byte("../..\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dir := range []string{repo, filepath.Join(repo, "src"), wt, filepath.Join(wt, "src")} {
+		if got := repoRoot(dir); got != repo {
+			t.Errorf("repoRoot(%s) = %q, want %q", dir, got, repo)
+		}
+	}
+	if got := repoRoot(base); got != "" {
+		t.Errorf("repoRoot outside a repository = %q, want \"\"", got)
+	}
+
+	// The parser records the branches per conversation; the repository is
+	// resolved as items are built.
+	path := filepath.Join(base, "s.jsonl")
+	content := `{"type":"user","cwd":"` + filepath.Join(wt, "src") + `","gitBranch":"feature/login","message":{"content":"add login"},"timestamp":"2026-01-15T10:00:00Z"}
+{"type":"assistant","gitBranch":"feature/login-v2","message":{"content":"done"},"timestamp":"2026-01-15T10:01:00Z"}
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	conv, err := parseConversationFile(path, time.Time{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, others := conv.latestBranch(); name != "feature/login-v2" || others != 1 {
+		t.Errorf("latestBranch() = %q, %d", name, others)
+	}
+
+	items := buildItems([]Conversation{
+		*conv,
+		{SessionID: "main", Cwd: repo, Branches: []string{"main"}, Messages: []Message{{Role: "user", Text: "x"}}},
+		{SessionID: "other", Cwd: base, Messages: []Message{{Role: "user", Text: "x"}}},
+	})
+	if c := items[0].conv; c.repo != repo || !c.worktree() || c.project() != repo {
+		t.Errorf("worktree session: repo %q, worktree %v, project %q", c.repo, c.worktree(), c.project())
+	}
+	m := initialModel(items, "", nil)
+	m.width, m.height, m.layout = 160, 30, layoutStacked
+
+	// Both checkouts show as the repository, and --here in either covers both.
+	for _, item := range items[:2] {
+		if row := m.formatListItem(item, true); !strings.Contains(row, "repo ") || strings.Contains(row, "repo-feature") {
+			t.Errorf("row should name the repository: %q", row)
+		}
+	}
+	for _, dir := range []string{repo, wt} {
+		sc := newHereScope(dir)
+		if !sc.contains(items[0].conv) || !sc.contains(items[1].conv) || sc.contains(items[2].conv) || sc.name != "repo" {
+			t.Errorf("scope from %s should be the repository with its worktrees: %+v", dir, sc)
+		}
+	}
+	if got := facetValues(facetProject, items[0].conv); !slices.Equal(got, []string{repo}) {
+		t.Errorf("project facet of a worktree session = %v", got)
+	}
+	if preview := m.renderPreview(items[0], 20); !strings.Contains(preview, "worktree of repo") || !strings.Contains(preview, "feature/login, feature/login-v2") {
+		t.Errorf("preview should note the worktree and list the branches:\n%s", preview)
+	}
+
+	// branch: matches any branch a session ran on.
+	for query, want := range map[string]int{"branch:login-v2": 1, "branch:main": 1, "branch:feature": 1, "-branch:main": 2, "branch:nope": 0} {
+		m.textInput.SetValue(query)
+		m.updateFilter()
+		if len(m.filtered) != want {
+			t.Errorf("%s matched %d conversations, want %d", query, len(m.filtered), want)
+		}
+	}
+
+	// Alt+V adds a BRANCH column; TOPIC gives up the room for it.
+	m.textInput.SetValue("")
+	m.updateFilter()
+	topic := m.topicColWidth()
+	res, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'v'}, Alt: true})
+	m = res.(model)
+	if !m.showBranch || m.topicColWidth() != topic-colBranch-colGap {
+		t.Fatalf("alt+v should show the BRANCH column (topic %d -> %d)", topic, m.topicColWidth())
+	}
+	if view := m.View(); !strings.Contains(view, "BRANCH") || !strings.Contains(view, "main") {
+		t.Error("the list should have a BRANCH column")
+	}
+	row := m.formatListItem(items[0], true)
+	if !strings.Contains(row, "feature/lo... +1  ") {
+		t.Errorf("a long branch should be cut keeping its +N: %q", row)
+	}
+	if w := ansiWidth(row); w != m.listWidth()-listIndent {
+		t.Errorf("row is %d cells wide, want %d", w, m.listWidth()-listIndent)
+	}
+}
+
+func TestUpdateKeyboardNavigation(t *testing.T) {
+	items := []listItem{
+		{conv: Conversation{SessionID: "test-1"}, searchText: "first"},
+		{conv: Conversation{SessionID: "test-2"}, searchText: "second"},
+		{conv: Conversation{SessionID: "test-3"}, searchText: "third"},
+	}
+
+	m := initialModel(items, "", nil)
 	m.width = 100
 	m.height = 30
 
@@ -1140,41 +2400,227 @@ func TestRenderPreview(t *testing.T) {
 		},
 	}
 
-	item := listItem{conv: conv, searchText: "test"}
-	m := initialModel([]listItem{item}, "query", nil)
-
-	preview := m.renderPreview(item, 20)
+	item := listItem{conv: conv, searchText: "test"}
+	m := initialModel([]listItem{item}, "query", nil)
+
+	preview := m.renderPreview(item, 20)
+
+	// Check preview contains key elements
+	if !strings.Contains(preview, "Project:") {
+		t.Error("preview should contain 'Project:' header")
+	}
+	if !strings.Contains(preview, "Session:") {
+		t.Error("preview should contain 'Session:' header")
+	}
+	if !strings.Contains(preview, "/test/project") {
+		t.Error("preview should contain project path")
+	}
+	if !strings.Contains(preview, "test-123") {
+		t.Error("preview should contain session ID")
+	}
+}
+
+func TestRenderPreviewLongMultibyteMessageStaysValidUTF8(t *testing.T) {
+	// A message longer than the 500-char preview cap, all multibyte: byte
+	// slicing would cut mid-rune at byte 500 and emit invalid UTF-8.
+	conv := Conversation{
+		SessionID: "s1",
+		Cwd:       "/p",
+		Messages:  []Message{{Role: "user", Text: strings.Repeat("世", 800), Ts: "2024-01-15T10:00:00Z"}},
+	}
+	item := listItem{conv: conv}
+	m := initialModel([]listItem{item}, "", nil)
+
+	if got := m.renderPreview(item, 40); !utf8.ValidString(got) {
+		t.Error("preview of a long multibyte message produced invalid UTF-8")
+	}
+}
+
+func TestPreviewExcerptsLongMatchingMessages(t *testing.T) {
+	long := strings.Repeat("a", 3000) + " the needle " + strings.Repeat("b", 3000)
+	conv := Conversation{SessionID: "s1", Messages: []Message{
+		{Role: "assistant", Text: long},
+		{Role: "user", Text: strings.Repeat("c", 800)},
+	}}
+	lines := strings.Join(buildPreviewLines(conv, parseQuery("needle"), previewOpts{context: 10}), "\n")
+	if !strings.Contains(lines, "… aaaaa the \033[43;30mneedle\033[0m bbbbbbbbb …") {
+		t.Errorf("expected an excerpt around the match, got:\n%s", lines)
+	}
+	if strings.Count(lines, "a") > 100 {
+		t.Error("excerpt should not include the head of the message")
+	}
+	// A long message without a match keeps the head truncation.
+	if !strings.Contains(lines, strings.Repeat("c", 500)+"... (truncated)") {
+		t.Error("non-matching long message should be truncated from the start")
+	}
+}
+
+func TestMatchWithoutMarksShowsAsUnmatched(t *testing.T) {
+	conv := Conversation{SessionID: "s1", Messages: []Message{
+		{Role: "assistant", Text: strings.Repeat("a", 3000)},
+		{Role: "assistant", Kind: kindThinking, Text: "weighing\nthe options"},
+		{Role: "assistant", Kind: kindToolUse, Tool: "Bash", Text: "go test ./..."},
+		{Role: "user", Kind: kindToolResult, Text: "ok\nPASS"},
+	}}
+	// Zero-width regexes match every message but mark nothing.
+	for _, query := range []string{"/^/", "/x*/"} {
+		lines := strings.Join(buildPreviewLines(conv, parseQuery(query), previewOpts{}), "\n")
+		if !strings.Contains(lines, ">>>") {
+			t.Errorf("%q: messages should still show as matched", query)
+		}
+		if !strings.Contains(lines, strings.Repeat("a", 500)+"... (truncated)") || strings.Count(lines, "a") > 520 {
+			t.Errorf("%q: a long message with nothing marked should be truncated from the start", query)
+		}
+
+		m := initialModel(buildItems([]Conversation{conv}), query, nil)
+		m.width, m.height = 100, 20
+		m.opts.tools, m.opts.thinking = true, true // search every message
+		res, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlO})
+		v := res.(model).viewer
+		all := strings.Join(v.lines, "\n")
+		for _, want := range []string{"Thinking (2 lines", "Result (2 lines)"} {
+			if !strings.Contains(all, want) {
+				t.Errorf("%q: transcript should keep %q collapsed", query, want)
+			}
+		}
+		if strings.Contains(all, "PASS") || len(matchLines(v.lines)) != 0 {
+			t.Errorf("%q: transcript should open and count nothing", query)
+		}
+	}
+}
+
+func TestExcerptMergesAndCapsWindows(t *testing.T) {
+	text := []rune("xx ab xx ab " + strings.Repeat("-", 50) + " ab")
+	_, marked := parseQuery("ab").marks(string(text))
+	got, gotMarks := excerpt(text, marked, 3)
+	if len(got) != len(gotMarks) {
+		t.Fatalf("runes and marks differ in length: %d vs %d", len(got), len(gotMarks))
+	}
+	// The first two matches are close enough to share a window.
+	if want := "xx ab xx ab -- … -- ab"; string(got) != want {
+		t.Errorf("excerpt = %q, want %q", string(got), want)
+	}
+
+	many := []rune(strings.Repeat("ab"+strings.Repeat(" ", 20), excerptMaxWindows+3))
+	_, marked = parseQuery("ab").marks(string(many))
+	got, _ = excerpt(many, marked, 2)
+	if !strings.HasSuffix(string(got), "(3 more matches)") {
+		t.Errorf("excerpt should count matches beyond the cap, got %q", string(got))
+	}
+}
+
+func TestJumpBetweenPreviewMatches(t *testing.T) {
+	var msgs []Message
+	for i := 0; i < 30; i++ {
+		text := "filler"
+		if i%10 == 5 {
+			text = "the needle"
+		}
+		msgs = append(msgs, Message{Role: "user", Text: text})
+	}
+	items := buildItems([]Conversation{{SessionID: "s1", Messages: msgs}})
+	m := initialModel(items, "needle", nil)
+	m.width, m.height = 120, 30
+	matches := matchLines(m.previewLines())
+	if len(matches) != 3 {
+		t.Fatalf("expected 3 match lines, got %v", matches)
+	}
+	if !strings.Contains(m.View(), "3 matches") {
+		t.Error("header should count the matches before any jump")
+	}
+
+	press := func(r rune) {
+		res, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}, Alt: true})
+		m = res.(model)
+	}
+	for k, want := range []int{1, 2, 3, 1} { // wraps at the end
+		press('n')
+		if m.previewScroll != matches[want-1]-matchContext {
+			t.Errorf("jump %d: scroll = %d, want match %d at line %d", k, m.previewScroll, want, matches[want-1])
+		}
+		if !strings.Contains(m.View(), fmt.Sprintf("match %d/3", want)) {
+			t.Errorf("jump %d: header should show match %d/3", k, want)
+		}
+	}
+	press('p') // wraps at the start
+	if currentMatch(matches, m.previewScroll) != 3 {
+		t.Errorf("alt+p from the first match should wrap to the last, scroll = %d", m.previewScroll)
+	}
+	press('p')
+	if currentMatch(matches, m.previewScroll) != 2 {
+		t.Errorf("alt+p should go back to match 2, scroll = %d", m.previewScroll)
+	}
+}
+
+func TestTranscriptViewer(t *testing.T) {
+	var msgs []Message
+	for i := 0; i < 40; i++ {
+		msgs = append(msgs, Message{Role: "user", Text: fmt.Sprintf("message %d", i)})
+	}
+	msgs[20].Text = "the needle here"
+	msgs = append(msgs,
+		Message{Role: "assistant", Kind: kindToolUse, Tool: "Bash", Text: "go test ./..."},
+		Message{Role: "user", Kind: kindToolResult, Text: "ok\nPASS"},
+	)
+	items := buildItems([]Conversation{
+		{SessionID: "other", Messages: []Message{{Role: "user", Text: "message"}}},
+		{SessionID: "s1", Messages: msgs},
+	})
+	m := initialModel(items, "message", nil)
+	m.width, m.height = 100, 20
+	m.cursor = 1
 
-	// Check preview contains key elements
-	if !strings.Contains(preview, "Project:") {
-		t.Error("preview should contain 'Project:' header")
+	key := func(k tea.KeyMsg) {
+		res, _ := m.Update(k)
+		m = res.(model)
 	}
-	if !strings.Contains(preview, "Session:") {
-		t.Error("preview should contain 'Session:' header")
+	typeText := func(text string) {
+		for _, r := range text {
+			key(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		}
 	}
-	if !strings.Contains(preview, "/test/project") {
-		t.Error("preview should contain project path")
+
+	key(tea.KeyMsg{Type: tea.KeyCtrlO})
+	if m.viewer == nil || m.viewer.conv.SessionID != "s1" {
+		t.Fatal("ctrl+o should open the selected conversation")
 	}
-	if !strings.Contains(preview, "test-123") {
-		t.Error("preview should contain session ID")
+	all := strings.Join(m.viewer.lines, "\n")
+	for _, want := range []string{"mmessage\033[0m 0\n", "mmessage\033[0m 39\n", "Tool: Bash\033[0m `go test ./...`", "Result (2 lines)"} {
+		if !strings.Contains(all, want) {
+			t.Errorf("transcript should show %q", want)
+		}
+	}
+	if strings.Contains(all, "PASS") {
+		t.Error("tool results should be collapsed")
 	}
-}
 
-func TestRenderPreviewLongMultibyteMessageStaysValidUTF8(t *testing.T) {
-	// A message longer than the 500-char preview cap, all multibyte: byte
-	// slicing would cut mid-rune at byte 500 and emit invalid UTF-8.
-	conv := Conversation{
-		SessionID: "s1",
-		Cwd:       "/p",
-		Messages:  []Message{{Role: "user", Text: strings.Repeat("世", 800), Ts: "2024-01-15T10:00:00Z"}},
+	// Incremental search: typing jumps to the match.
+	key(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'/'}})
+	key(tea.KeyMsg{Type: tea.KeyCtrlU}) // clear the list's query copied in
+	typeText("needle")
+	key(tea.KeyMsg{Type: tea.KeyEnter})
+	view := m.View()
+	if !strings.Contains(view, "the \033[43;30mneedle\033[0m here") || !strings.Contains(view, "match 1/1") {
+		t.Errorf("search should scroll to the highlighted match:\n%s", view)
+	}
+	typeText("G")
+	if m.viewer.scroll != m.viewer.maxScroll(m.transcriptHeight()) {
+		t.Error("G should scroll to the end")
+	}
+	typeText("n")
+	if currentMatch(matchLines(m.viewer.lines), m.viewer.scroll) != 1 {
+		t.Error("n should wrap around to the match")
 	}
-	item := listItem{conv: conv}
-	m := initialModel([]listItem{item}, "", nil)
 
-	if got := m.renderPreview(item, 40); !utf8.ValidString(got) {
-		t.Error("preview of a long multibyte message produced invalid UTF-8")
+	key(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.viewer != nil || m.quitting {
+		t.Fatal("esc should return to the list")
+	}
+	if m.cursor != 1 || m.textInput.Value() != "message" {
+		t.Errorf("list state should be intact: cursor %d, query %q", m.cursor, m.textInput.Value())
 	}
 }
+
 func TestPreviewScrollClampedToContent(t *testing.T) {
 	conv := Conversation{SessionID: "s1", Messages: []Message{
 		{Role: "user", Text: "only message", Ts: "2024-01-15T10:00:00Z"},
@@ -1203,6 +2649,166 @@ func TestPreviewScrollClampedToContent(t *testing.T) {
 	}
 }
 
+func TestWrapLine(t *testing.T) {
+	hl := highlightStart
+	tests := []struct {
+		name  string
+		line  string
+		width int
+		want  []string
+	}{
+		{"fits", "    short", 20, []string{"    short"}},
+		{"breaks after spaces, keeps indent", "    one two three four", 14, []string{"    one two ", "    three four"}},
+		{"hard-breaks long words", "abcdefghij", 4, []string{"abcd", "efgh", "ij"}},
+		{"drops the space at a break", "abcd efgh", 4, []string{"abcd", "efgh"}},
+		{"wide runes count two cells", "日本語テキスト", 6, []string{"日本語", "テキス", "ト"}},
+		{"escapes take no cells", "\033[32mab\033[0m cd", 5, []string{"\033[32mab\033[0m cd"}},
+		{"highlight reopened across a break", "x " + hl + "a b cc\033[0m", 6,
+			[]string{"x " + hl + "a b \033[0m", highlightResume + "cc\033[0m"}},
+		{"highlight reopened across a hard break", hl + "abcdef\033[0m", 4,
+			[]string{hl + "abcd\033[0m", highlightResume + "ef\033[0m"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := wrapLine(tt.line, tt.width)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("wrapLine(%q, %d) = %q, want %q", tt.line, tt.width, got, tt.want)
+			}
+			for _, seg := range got {
+				if ansiWidth(seg) > tt.width {
+					t.Errorf("segment %q is %d cells, wider than %d", seg, ansiWidth(seg), tt.width)
+				}
+			}
+		})
+	}
+}
+
+func TestPreviewWrapsToTerminalWidth(t *testing.T) {
+	long := strings.Repeat("word ", 60) + "needle"
+	conv := Conversation{SessionID: "s1", Messages: []Message{
+		{Role: "user", Text: long, Ts: "2024-01-15T10:00:00Z"},
+	}}
+	m := initialModel(buildItems([]Conversation{conv}), "needle", nil)
+	res, _ := m.Update(tea.WindowSizeMsg{Width: 40, Height: 30})
+	m = res.(model)
+
+	lines := m.previewLines()
+	for _, line := range lines {
+		if w := ansiWidth(line); w > 40 {
+			t.Errorf("line %q is %d cells wide, terminal is 40", line, w)
+		}
+	}
+	matches := matchLines(lines)
+	if len(matches) != 1 || !strings.Contains(lines[matches[0]], "needle") {
+		t.Fatalf("the match should be on one wrapped line, got %v", matches)
+	}
+	if m.maxPreviewScroll() != len(lines)-1 {
+		t.Errorf("scroll clamp %d should count wrapped lines (%d)", m.maxPreviewScroll(), len(lines))
+	}
+
+	// Widening the terminal rewraps and pulls the scroll back within range.
+	for range 10 {
+		res, _ = m.Update(tea.KeyMsg{Type: tea.KeyPgDown})
+		m = res.(model)
+	}
+	res, _ = m.Update(tea.WindowSizeMsg{Width: 400, Height: 30})
+	m = res.(model)
+	if n := len(m.previewLines()); n >= len(lines) || m.previewScroll > n-1 {
+		t.Errorf("after widening: %d lines (was %d), scroll %d", n, len(lines), m.previewScroll)
+	}
+}
+
+func TestMatchAcrossWrapCountsOnce(t *testing.T) {
+	conv := Conversation{SessionID: "s1", Messages: []Message{
+		{Role: "user", Text: strings.Repeat("x", 30) + "needleneedle tail, then another needle", Ts: "2024-01-15T10:00:00Z"},
+	}}
+	m := initialModel(buildItems([]Conversation{conv}), "/(needle)+/", nil)
+	res, _ := m.Update(tea.WindowSizeMsg{Width: 40, Height: 30})
+	m = res.(model)
+
+	lines := m.previewLines()
+	var wrapped int // the line the first match wraps onto
+	for i, line := range lines {
+		if strings.HasPrefix(line, "    "+highlightResume) {
+			wrapped = i
+		}
+	}
+	if wrapped == 0 {
+		t.Fatalf("the first match should wrap, got:\n%s", strings.Join(lines, "\n"))
+	}
+	matches := matchLines(lines)
+	if len(matches) != 2 || matches[0] != wrapped-1 {
+		t.Errorf("match lines = %v, want the wrapped match counted once, at %d", matches, wrapped-1)
+	}
+	if view := m.View(); !strings.Contains(view, "match 1/2") && !strings.Contains(view, "2 matches") {
+		t.Errorf("the header should count 2 matches:\n%s", view)
+	}
+}
+
+func TestMarkdownLines(t *testing.T) {
+	md := &markdown{}
+	render := func(line string) string {
+		return md.line([]rune(line), nil)
+	}
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"plain text", "plain text"},
+		{"# Title", mdHeading + "Title\033[0m"},
+		{"### Step `one`", mdSubheading + "Step \033[0m" + mdCode + "one\033[0m"},
+		{"  - item", "  " + mdBullet + "•\033[0m item"},
+		{"run `go test` now", "run " + mdCode + "go test\033[0m now"},
+		{"a **bold** move", "a " + mdBold + "bold\033[0m move"},
+		{"2 * 3 and `unclosed", "2 * 3 and `unclosed"},
+		{"#hashtag", "#hashtag"},
+		{"```go", mdFence + "```go\033[0m"},
+		{`	x := "s" // note`, "\tx := " + codeString + `"s"` + "\033[0m " + codeComment + "// note\033[0m"},
+		{"return 42", codeKeyword + "return\033[0m " + codeNumber + "42\033[0m"},
+		{"# not a heading in code", "# not a heading in code"},
+		{"```", mdFence + "```\033[0m"},
+		{"# Back to markdown", mdHeading + "Back to markdown\033[0m"},
+	}
+	for _, tt := range tests {
+		if got := render(tt.line); got != tt.want {
+			t.Errorf("line(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+
+	// Matches are highlighted over the styling; markup is dropped from both.
+	tr := []rune("use `fmt.Println` here")
+	marked := make([]bool, len(tr))
+	for i := 5; i < 8; i++ { // "fmt"
+		marked[i] = true
+	}
+	want := "use " + highlightStart + "fmt\033[0m" + mdCode + ".Println\033[0m here"
+	if got := (&markdown{}).line(tr, marked); got != want {
+		t.Errorf("highlighted inline code = %q, want %q", got, want)
+	}
+	if got := (*markdown)(nil).line(tr, marked); got != "use `"+highlightStart+"fmt\033[0m.Println` here" {
+		t.Errorf("nil markdown should render raw, got %q", got)
+	}
+}
+
+func TestPreviewMarkdownToggle(t *testing.T) {
+	conv := Conversation{SessionID: "s1", Messages: []Message{
+		{Role: "user", Text: "how do I test?"},
+		{Role: "assistant", Text: "## Testing\nRun `go test`."},
+	}}
+	m := initialModel(buildItems([]Conversation{conv}), "", nil)
+	joined := strings.Join(m.previewLines(), "\n")
+	if strings.Contains(joined, "## Testing") || !strings.Contains(joined, mdSubheading+"Testing") {
+		t.Errorf("preview should style markdown by default:\n%s", joined)
+	}
+
+	res, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'m'}, Alt: true})
+	m = res.(model)
+	joined = strings.Join(m.previewLines(), "\n")
+	if !strings.Contains(joined, "## Testing") || !strings.Contains(joined, "Run `go test`.") {
+		t.Errorf("Alt+M should show the raw text:\n%s", joined)
+	}
+}
+
 func TestDeleteConversationFullFlow(t *testing.T) {
 	// Create temp directory that will act as projects dir
 	tmpDir := t.TempDir()
@@ -1388,12 +2994,383 @@ func TestGetConversationsSkipsSubagents(t *testing.T) {
 	}
 }
 
+func TestGetConversationsUsesCacheForUnchangedFiles(t *testing.T) {
+	projects := t.TempDir()
+	cacheDir := t.TempDir()
+	oldProjects, oldCache := getProjectsDir, getCacheDir
+	getProjectsDir = func() string { return projects }
+	getCacheDir = func() string { return cacheDir }
+	defer func() { getProjectsDir, getCacheDir = oldProjects, oldCache }()
+
+	path := filepath.Join(projects, "s1.jsonl")
+	write := func(text string, mtime time.Time) {
+		t.Helper()
+		content := `{"type":"user","cwd":"/p","message":{"content":"` + text + `"},"timestamp":"2024-01-15T10:00:00Z"}`
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	firstText := func() string {
+		t.Helper()
+		convs, err := getConversations(time.Time{}, 0, nil)
+		if err != nil || len(convs) != 1 {
+			t.Fatalf("getConversations = %d convs, err %v", len(convs), err)
+		}
+		return convs[0].Messages[0].Text
+	}
+
+	mtime := time.Now().Add(-time.Hour)
+	write("alpha", mtime)
+	if got := firstText(); got != "alpha" {
+		t.Fatalf("first load = %q, want alpha", got)
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, "conversations.gob")); err != nil {
+		t.Fatalf("cache file not written: %v", err)
+	}
+
+	// Same size and mtime: served from the cache, so the rewrite goes unseen.
+	write("bravo", mtime)
+	if got := firstText(); got != "alpha" {
+		t.Errorf("unchanged size+mtime should hit the cache, got %q", got)
+	}
+
+	// A new mtime invalidates the entry.
+	write("bravo", mtime.Add(time.Minute))
+	if got := firstText(); got != "bravo" {
+		t.Errorf("changed mtime should reparse, got %q", got)
+	}
+}
+
+func TestCacheParsesOnlyAppendedLines(t *testing.T) {
+	c := &convCache{entries: make(map[string]*cacheEntry)}
+	path := filepath.Join(t.TempDir(), "s1.jsonl")
+	line := func(text string) string {
+		return `{"type":"user","cwd":"/p","message":{"content":"` + text + `"},"timestamp":"2024-01-15T10:00:00Z"}` + "\n"
+	}
+	texts := func() []string {
+		t.Helper()
+		conv, err := c.parseConversationFile(path, time.Time{}, 0)
+		if err != nil || conv == nil {
+			t.Fatalf("parse: conv %v, err %v", conv, err)
+		}
+		var out []string
+		for _, m := range conv.Messages {
+			out = append(out, m.Text)
+		}
+		return out
+	}
+
+	os.WriteFile(path, []byte(line("alpha")+line("bravo")), 0644)
+	if got := texts(); len(got) != 2 {
+		t.Fatalf("initial parse = %v, want 2 messages", got)
+	}
+
+	// Alter already-consumed bytes (outside the tail fingerprint) and append:
+	// only the new line is read, so the stale "alpha" survives.
+	data, _ := os.ReadFile(path)
+	data = bytes.Replace(data, []byte("alpha"), []byte("ALPHA"), 1)
+	f, _ := os.OpenFile(path, os.O_WRONLY, 0)
+	f.Write(data)
+	f.WriteString(line("charlie"))
+	// A half-written line is not consumed...
+	f.WriteString(`{"type":"user","message":{"con`)
+	f.Close()
+	if got := texts(); strings.Join(got, ",") != "alpha,bravo,charlie" {
+		t.Errorf("appended parse = %v, want alpha,bravo,charlie", got)
+	}
+	// ...until it is complete.
+	f, _ = os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString(`tent":"delta"},"timestamp":"2024-01-15T10:01:00Z"}` + "\n")
+	f.Close()
+	if got := texts(); strings.Join(got, ",") != "alpha,bravo,charlie,delta" {
+		t.Errorf("completed line parse = %v, want alpha,bravo,charlie,delta", got)
+	}
+
+	// A file that shrank (e.g. pruned) is reparsed from the start.
+	os.WriteFile(path, []byte(line("echo")), 0644)
+	if got := texts(); strings.Join(got, ",") != "echo" {
+		t.Errorf("shrunk file should be fully reparsed, got %v", got)
+	}
+}
+
+func TestClonedStatesResumeIndependently(t *testing.T) {
+	apply := func(st *parseState, branch, model string) {
+		var raw RawMessage
+		line := fmt.Sprintf(`{"type":"assistant","gitBranch":%q,"message":{"model":%q}}`, branch, model)
+		if err := json.Unmarshal([]byte(line), &raw); err != nil {
+			t.Fatal(err)
+		}
+		st.apply(raw)
+	}
+	st := newParseState("/p/s1.jsonl")
+	for _, v := range []string{"a", "b", "c"} { // leaves spare capacity
+		apply(st, v, "model-"+v)
+	}
+	// The loader and the watcher may resume the same cached state at once.
+	loader, watcher := st.clone(), st.clone()
+	apply(loader, "loader", "model-loader")
+	apply(watcher, "watcher", "model-watcher")
+	if !slices.Equal(loader.Conv.Branches, []string{"a", "b", "c", "loader"}) ||
+		!slices.Equal(loader.Conv.Models, []string{"model-a", "model-b", "model-c", "model-loader"}) {
+		t.Errorf("the loader's copy was overwritten: %v %v", loader.Conv.Branches, loader.Conv.Models)
+	}
+	if len(st.Conv.Branches) != 3 || len(st.Conv.Models) != 3 {
+		t.Errorf("the cached state grew: %v %v", st.Conv.Branches, st.Conv.Models)
+	}
+}
+
+func TestCacheResumesFromConsumedOffset(t *testing.T) {
+	c := &convCache{entries: make(map[string]*cacheEntry)}
+	path := filepath.Join(t.TempDir(), "s1.jsonl")
+	first := `{"type":"user","cwd":"/p","message":{"content":"alpha"},"timestamp":"2024-01-15T10:00:00Z"}` + "\n"
+	second := `{"type":"user","message":{"content":"bravo"},"timestamp":"2024-01-15T10:01:00Z"}`
+
+	// A line appended after the stat is left for the next parse, so the state
+	// never claims more than the size it is cached under.
+	os.WriteFile(path, []byte(first), 0644)
+	info, _ := os.Stat(path)
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString(second + "\n")
+	f.Close()
+	st := newParseState(path)
+	if err := st.parseFile(info); err != nil {
+		t.Fatal(err)
+	}
+	if st.Offset != info.Size() || len(st.Conv.Messages) != 1 {
+		t.Fatalf("parse of a growing file: offset %d (size %d), %d messages", st.Offset, info.Size(), len(st.Conv.Messages))
+	}
+
+	// A file that ended mid-line is resumed even when a rewrite of that line
+	// keeps its size and mtime.
+	os.WriteFile(path, []byte(first+strings.Repeat("x", len(second))), 0644)
+	info, _ = os.Stat(path)
+	if conv, err := c.parseConversationFile(path, time.Time{}, 0); err != nil || len(conv.Messages) != 1 {
+		t.Fatalf("partial line parse: %v, %v", conv, err)
+	}
+	os.WriteFile(path, []byte(first+second), 0644)
+	os.Chtimes(path, info.ModTime(), info.ModTime())
+	conv, err := c.parseConversationFile(path, time.Time{}, 0)
+	if err != nil || len(conv.Messages) != 2 || conv.Messages[1].Text != "bravo" {
+		t.Errorf("completed line should be parsed, got %+v (err %v)", conv, err)
+	}
+}
+
+func TestLoadCacheDiscardsOtherVersions(t *testing.T) {
+	cacheDir := t.TempDir()
+	oldCache := getCacheDir
+	getCacheDir = func() string { return cacheDir }
+	defer func() { getCacheDir = oldCache }()
+
+	c := loadCache()
+	c.store("/x.jsonl", &cacheEntry{Size: 1, ModTime: 1, State: newParseState("/x.jsonl")})
+	if err := c.save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if got := loadCache(); len(got.entries) != 1 {
+		t.Fatalf("reloaded cache has %d entries, want 1", len(got.entries))
+	}
+
+	// Rewrite the file as if by an older parser: it must be ignored.
+	f, err := os.Create(filepath.Join(cacheDir, "conversations.gob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gob.NewEncoder(f).Encode(cacheFile{Version: cacheVersion - 1, Entries: c.entries})
+	f.Close()
+	if got := loadCache(); len(got.entries) != 0 {
+		t.Errorf("cache from another version should be discarded, got %d entries", len(got.entries))
+	}
+
+	// Disabled caching is a nil cache, which every method tolerates.
+	getCacheDir = func() string { return "" }
+	if c := loadCache(); c != nil || c.save() != nil {
+		t.Error("empty cache dir should disable caching")
+	}
+}
+
+func TestConvsChangedMsgKeepsSelectionAndQuery(t *testing.T) {
+	conv := func(id, ts, text string) Conversation {
+		return Conversation{SessionID: id, FilePath: "/p/" + id + ".jsonl", Cwd: "/p", LastTimestamp: ts,
+			Messages: []Message{{Role: "user", Text: text, Ts: ts}}}
+	}
+	items := buildItems([]Conversation{
+		conv("a", "2024-01-15T12:00:00Z", "deploy alpha"),
+		conv("b", "2024-01-15T11:00:00Z", "deploy bravo"),
+		conv("c", "2024-01-15T10:00:00Z", "unrelated"),
+	})
+	m := initialModel(items, "deploy", nil)
+	m.cursor = 1 // "b"
+
+	res, _ := m.Update(convsChangedMsg{
+		updated: buildItems([]Conversation{
+			conv("d", "2024-01-15T13:00:00Z", "deploy delta"),       // new, matches
+			conv("e", "2024-01-15T14:00:00Z", "nothing to see"),     // new, filtered out
+			conv("b", "2024-01-15T11:00:00Z", "deploy bravo again"), // grew
+		}),
+		removed: []string{"/p/a.jsonl"},
+	})
+	m = res.(model)
+
+	if m.textInput.Value() != "deploy" {
+		t.Errorf("query should be preserved, got %q", m.textInput.Value())
+	}
+	if len(m.items) != 4 {
+		t.Errorf("items = %d, want 4 (a removed, d and e added)", len(m.items))
+	}
+	var ids []string
+	for _, item := range m.filtered {
+		ids = append(ids, item.conv.SessionID)
+	}
+	if strings.Join(ids, ",") != "d,b" {
+		t.Fatalf("filtered = %v, want [d b]", ids)
+	}
+	if got := m.filtered[m.cursor].conv.SessionID; got != "b" {
+		t.Errorf("cursor should stay on b, got %s", got)
+	}
+	if got := m.filtered[m.cursor].conv.Messages[0].Text; got != "deploy bravo again" {
+		t.Errorf("grown conversation not refreshed, got %q", got)
+	}
+}
+
+func TestWatchProjectsReportsNewAndGrownSessions(t *testing.T) {
+	projects := t.TempDir()
+	oldProjects := getProjectsDir
+	getProjectsDir = func() string { return projects }
+	defer func() { getProjectsDir = oldProjects }()
+
+	msgs := make(chan convsChangedMsg, 10)
+	stop, err := watchProjects(nil, time.Time{}, 0, nil, func(msg tea.Msg) { msgs <- msg.(convsChangedMsg) })
+	if err != nil {
+		t.Skipf("file watching unavailable: %v", err)
+	}
+	defer stop()
+
+	// A session in a project directory created after the watch started.
+	dir := filepath.Join(projects, "-home-user-proj")
+	os.MkdirAll(dir, 0755)
+	path := filepath.Join(dir, "s1.jsonl")
+	os.WriteFile(path, []byte(`{"type":"user","cwd":"/p","message":{"content":"hi"},"timestamp":"2024-01-15T10:00:00Z"}`+"\n"), 0644)
+
+	wait := func() convsChangedMsg {
+		t.Helper()
+		select {
+		case msg := <-msgs:
+			return msg
+		case <-time.After(5 * time.Second):
+			t.Fatal("no change reported")
+			return convsChangedMsg{}
+		}
+	}
+	if msg := wait(); len(msg.updated) != 1 || msg.updated[0].conv.SessionID != "s1" {
+		t.Fatalf("expected s1 reported as new, got %+v", msg)
+	} else if !strings.Contains(msg.updated[0].searchLower, "hi") {
+		t.Error("the watcher should hand over an indexed item")
+	}
+
+	os.Remove(path)
+	if msg := wait(); len(msg.removed) != 1 || msg.removed[0] != path {
+		t.Errorf("expected %s reported as removed, got %+v", path, msg)
+	}
+}
+
+func TestLoadInBackgroundStreamsNewestFilesFirst(t *testing.T) {
+	projects := t.TempDir()
+	oldProjects := getProjectsDir
+	getProjectsDir = func() string { return projects }
+	defer func() { getProjectsDir = oldProjects }()
+
+	now := time.Now()
+	for i, id := range []string{"old", "mid", "new"} {
+		path := filepath.Join(projects, id+".jsonl")
+		os.WriteFile(path, []byte(`{"type":"user","cwd":"/p","message":{"content":"`+id+`"},"timestamp":"2024-01-15T10:00:00Z"}`), 0644)
+		mtime := now.Add(time.Duration(i-3) * time.Hour)
+		os.Chtimes(path, mtime, mtime)
+	}
+	files, err := conversationFiles(nil)
+	if err != nil || len(files) != 3 {
+		t.Fatalf("conversationFiles = %v, %v", files, err)
+	}
+	if filepath.Base(files[0]) != "new.jsonl" || filepath.Base(files[2]) != "old.jsonl" {
+		t.Errorf("files should be newest mtime first, got %v", files)
+	}
+
+	var msgs []loadProgressMsg
+	loadInBackground(nil, time.Time{}, 0, nil, func(msg tea.Msg) { msgs = append(msgs, msg.(loadProgressMsg)) })
+	last := msgs[len(msgs)-1]
+	if last.done != 3 || last.total != 3 {
+		t.Errorf("final progress = %d/%d, want 3/3", last.done, last.total)
+	}
+	for _, msg := range msgs[1 : len(msgs)-1] {
+		if len(msg.items) == 0 {
+			t.Errorf("progress %d/%d carries no conversations", msg.done, msg.total)
+		}
+	}
+
+	m := initialModel(nil, "", nil)
+	m.loading = true
+	m.width, m.height = 120, 30
+	res, _ := m.Update(loadProgressMsg{done: 1, total: 3})
+	m = res.(model)
+	if !m.loading || !strings.Contains(m.View(), "loaded 1/3 files") {
+		t.Error("view should show loading progress while files remain")
+	}
+	for _, msg := range msgs {
+		res, _ = m.Update(msg)
+		m = res.(model)
+	}
+	if m.loading || len(m.items) != 3 {
+		t.Errorf("after loading: loading=%v items=%d, want false/3", m.loading, len(m.items))
+	}
+	if strings.Contains(m.View(), "loaded") {
+		t.Error("progress line should disappear once loading finishes")
+	}
+}
+
 func TestPrintHelp(t *testing.T) {
 	// Just call it to ensure no panics - we can't easily test stdout
 	// but this at least ensures the function doesn't crash
 	printHelp()
 }
 
+func TestParseSearchArgs(t *testing.T) {
+	cfg, filter, claude, err := parseSearchArgs([]string{"--tools", "--max-age=7", "buyer", "--layout=side", "later", "--", "--plan", "--all"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.opts.tools || cfg.maxAgeDays != 7 || cfg.maxSizeMB != 1024 || cfg.layout != layoutSide {
+		t.Errorf("flags not applied: %+v", cfg)
+	}
+	if filter != "buyer" {
+		t.Errorf("filter query = %q, want the first non-flag argument", filter)
+	}
+	if !slices.Equal(claude, []string{"--plan", "--all"}) {
+		t.Errorf("claude flags = %q, want everything after --", claude)
+	}
+
+	cfg, _, _, err = parseSearchArgs([]string{"--case=ignore", "--context=80", "--split=40"})
+	if err != nil || cfg.opts.caseMode != caseIgnore || cfg.display.context != 80 || cfg.listPct != 40 {
+		t.Errorf("valid values: %+v, %v", cfg, err)
+	}
+	// Invalid values are reported, not silently replaced by a default.
+	for _, arg := range []string{"--case=nocase", "--case=", "--context=abc", "--context=-5", "--max-age=7d",
+		"--max-size=big", "--layout=wide", "--split=abc", "--split=95"} {
+		if _, _, _, err := parseSearchArgs([]string{arg}); err == nil || !strings.HasPrefix(err.Error(), arg+": ") {
+			t.Errorf("%s: error %v, want one naming the flag", arg, err)
+		}
+	}
+
+	// Every flag the help documents is in the table, so none is mistaken for
+	// the filter query.
+	for _, f := range []string{"--all", "--no-cache", "--fuzzy", "--regex", "--tools", "--thinking", "--raw", "--here", "--branch-column",
+		"--max-age=", "--max-size=", "--exclude=", "--case=", "--context=", "--layout=", "--split="} {
+		if _, _, ok := lookupSearchFlag(f); !ok {
+			t.Errorf("%s is missing from searchFlags", f)
+		}
+	}
+}
 
 func TestPruneStreamRemovesDuplicatesKeepsDialogue(t *testing.T) {
 	input := strings.Join([]string{
@@ -1572,3 +3549,174 @@ func TestCtrlREntersAndCancelsPruneConfirm(t *testing.T) {
 		t.Error("esc should cancel prune confirm")
 	}
 }
+
+func TestMarkedConversationsDeleteAsABatch(t *testing.T) {
+	dir := t.TempDir()
+	var convs []Conversation
+	for i, id := range []string{"a", "b", "c", "d"} {
+		path := filepath.Join(dir, id+".jsonl")
+		if err := os.WriteFile(path, []byte(strings.Repeat("x", 1000)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		convs = append(convs, Conversation{SessionID: id, FilePath: path, Size: 1000,
+			LastTimestamp: fmt.Sprintf("2026-01-0%dT10:00:00Z", 9-i), Messages: []Message{{Role: "user", Text: "topic " + id}}})
+	}
+	m := initialModel(buildItems(convs), "", nil)
+	m.width, m.height = 120, 30
+	press := func(msg tea.KeyMsg) {
+		res, _ := m.Update(msg)
+		m = res.(model)
+	}
+	tab := tea.KeyMsg{Type: tea.KeyTab}
+
+	// Tab marks and moves down; a second Tab on a row unmarks it.
+	press(tab) // a
+	press(tab) // b
+	press(tea.KeyMsg{Type: tea.KeyUp})
+	press(tab) // b again
+	press(tab) // c
+	if !m.marked["a"] || m.marked["b"] || !m.marked["c"] || m.cursor != 3 {
+		t.Fatalf("marks %v, cursor %d", m.marked, m.cursor)
+	}
+	view := m.View()
+	if !strings.Contains(view, "2 marked, 1KB") || !strings.Contains(view, "*") {
+		t.Errorf("the list should show the marks and their count:\n%s", view)
+	}
+
+	// Marks survive a search that hides them.
+	m.textInput.SetValue("topic d")
+	m.updateFilter()
+	press(tea.KeyMsg{Type: tea.KeyCtrlD})
+	if !m.confirmDelete || !m.batch || !strings.Contains(m.View(), "Delete 2 marked conversations (1KB)?") {
+		t.Fatalf("ctrl+d should confirm the marked batch:\n%s", m.View())
+	}
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'y'}})
+	for id, want := range map[string]bool{"a": false, "b": true, "c": false, "d": true} {
+		if _, err := os.Stat(filepath.Join(dir, id+".jsonl")); (err == nil) != want {
+			t.Errorf("%s.jsonl exists: %v, want %v", id, err == nil, want)
+		}
+	}
+	if len(m.marked) != 0 || len(m.items) != 2 || m.selectedID() != "d" || m.errorMsg != "" {
+		t.Errorf("after the batch: marks %v, %d items, selected %q, error %q", m.marked, len(m.items), m.selectedID(), m.errorMsg)
+	}
+
+	// Without marks Ctrl+D is about the selected conversation again; Alt+A
+	// marks everything listed, and again unmarks it.
+	m.textInput.SetValue("")
+	m.updateFilter()
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}, Alt: true})
+	if len(m.marked) != 2 {
+		t.Errorf("alt+a should mark both conversations, got %v", m.marked)
+	}
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}, Alt: true})
+	press(tea.KeyMsg{Type: tea.KeyCtrlD})
+	if len(m.marked) != 0 || m.batch || !strings.Contains(m.View(), `Delete conversation "topic`) {
+		t.Errorf("alt+a again should unmark, leaving ctrl+d for one conversation:\n%s", m.View())
+	}
+}
+
+func TestMarkedConversationsExportAsABatch(t *testing.T) {
+	var convs []Conversation
+	for i, id := range []string{"a", "b", "c"} {
+		convs = append(convs, Conversation{SessionID: id, Cwd: "/home/user/api", Size: 1000,
+			LastTimestamp: fmt.Sprintf("2026-01-0%dT10:00:00Z", 9-i), Messages: []Message{
+				{Role: "user", Text: "topic " + id, Ts: "2026-01-01T10:00:00Z"},
+				{Role: "assistant", Kind: kindToolUse, Tool: "Bash", Text: "ls"},
+				{Role: "assistant", Text: "answer " + id},
+			}})
+	}
+	m := initialModel(buildItems(convs), "", nil)
+	m.width, m.height = 120, 30
+	m.exportDir = filepath.Join(t.TempDir(), "out")
+	press := func(msg tea.KeyMsg) {
+		res, _ := m.Update(msg)
+		m = res.(model)
+	}
+	press(tea.KeyMsg{Type: tea.KeyTab}) // a
+	press(tea.KeyMsg{Type: tea.KeyDown})
+	press(tea.KeyMsg{Type: tea.KeyTab}) // c
+
+	// One confirmation with the totals; declining writes nothing.
+	press(tea.KeyMsg{Type: tea.KeyCtrlX})
+	if !m.confirmExport || !strings.Contains(m.View(), "Export 2 marked conversations (1KB) to "+m.exportDir) {
+		t.Fatalf("ctrl+x should confirm the marked batch:\n%s", m.View())
+	}
+	press(tea.KeyMsg{Type: tea.KeyEsc})
+	if _, err := os.Stat(m.exportDir); m.confirmExport || err == nil {
+		t.Fatal("esc should cancel the export")
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyCtrlX})
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'y'}})
+	for id, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		data, err := os.ReadFile(filepath.Join(m.exportDir, id+".md"))
+		if (err == nil) != want {
+			t.Errorf("%s.md exists: %v, want %v", id, err == nil, want)
+			continue
+		}
+		if want {
+			md := string(data)
+			for _, part := range []string{"# topic " + id, "- Project: `/home/user/api`", "## User · 2026-01-01", "answer " + id} {
+				if !strings.Contains(md, part) {
+					t.Errorf("%s.md should contain %q:\n%s", id, part, md)
+				}
+			}
+			if strings.Contains(md, "Bash") {
+				t.Errorf("%s.md should leave out tool calls:\n%s", id, md)
+			}
+		}
+	}
+	if len(m.marked) != 0 || m.confirmExport || m.errorMsg != "" {
+		t.Errorf("after the batch: marks %v, confirming %v, error %q", m.marked, m.confirmExport, m.errorMsg)
+	}
+
+	// Without marks Ctrl+X exports the selected conversation.
+	press(tea.KeyMsg{Type: tea.KeyCtrlX})
+	if m.batch || !strings.Contains(m.View(), `Export "topic c" (1000B)`) {
+		t.Errorf("ctrl+x without marks should be about one conversation:\n%s", m.View())
+	}
+}
+
+func TestMarkedConversationsPruneAsABatch(t *testing.T) {
+	dir := t.TempDir()
+	var items []listItem
+	for _, id := range []string{"s1", "s2", "s3"} {
+		path := filepath.Join(dir, id+".jsonl")
+		content := `{"type":"user","message":{"content":"keep"},"uuid":"u1"}` + "\n" +
+			`{"type":"file-history-snapshot","snapshot":{"data":"` + strings.Repeat("x", 4000) + `"}}` + "\n"
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		info, _ := os.Stat(path)
+		items = append(items, listItem{conv: Conversation{SessionID: id, FilePath: path, Size: info.Size(),
+			Messages: []Message{{Role: "user", Text: "keep"}}}})
+	}
+	m := initialModel(items, "", nil)
+	m.width, m.height = 120, 30
+	m.toggleMark("s1")
+	m.toggleMark("s3")
+
+	res, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlR})
+	m = res.(model)
+	if !m.confirmPrune || !m.batch || m.pruneSaved < 2*4000 {
+		t.Fatalf("ctrl+r should measure the marked batch (saves %d, err %q)", m.pruneSaved, m.errorMsg)
+	}
+	if view := m.View(); !strings.Contains(view, "Prune 2 marked conversations? 8KB -> ") {
+		t.Errorf("the prompt should show the batch totals:\n%s", view)
+	}
+	res, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'y'}})
+	m = res.(model)
+
+	if m.confirmPrune || len(m.marked) != 0 || m.errorMsg != "" {
+		t.Fatalf("after the batch: confirm %v, marks %v, error %q", m.confirmPrune, m.marked, m.errorMsg)
+	}
+	for i, item := range m.items {
+		info, _ := os.Stat(item.conv.FilePath)
+		if pruned := info.Size() < 4000; pruned != (i != 1) {
+			t.Errorf("%s is %d bytes; only the marked should be pruned", item.conv.SessionID, info.Size())
+		}
+		if item.conv.Size != info.Size() || m.filtered[i].conv.Size != info.Size() {
+			t.Errorf("%s: displayed size %d, file %d", item.conv.SessionID, item.conv.Size, info.Size())
+		}
+	}
+}